@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66-oss/cx/term"
+)
+
+// flagTimeout and flagPollInterval are set from the global --timeout and --poll-interval flags.
+// The defaults of each command are used when they are 0
+var (
+	flagTimeout      time.Duration
+	flagPollInterval time.Duration
+)
+
+var progressFrames = []string{"|", "/", "-", "\\"}
+
+// actionProgressEvent is written to stderr as a line of JSON for each check of an action when stderr
+// is not a terminal
+type actionProgressEvent struct {
+	// Event is started, running, finished or timed_out
+	Event    string    `json:"event"`
+	ActionId int       `json:"action_id"`
+	Action   string    `json:"action"`
+	StackUid string    `json:"stack_uid"`
+	Status   string    `json:"status"`
+	Elapsed  float64   `json:"elapsed_seconds"`
+	Message  string    `json:"message,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// actionProgress shows the progress of an action being waited for: a line with the elapsed time
+// on a terminal, or progress events for CI
+type actionProgress struct {
+	stackUid string
+	out      io.Writer
+	live     bool
+	started  time.Time
+
+	lock   sync.Mutex
+	action cloud66.AsyncResult
+	seen   bool
+	stop   chan struct{}
+	drawn  sync.WaitGroup
+}
+
+func newActionProgress(stackUid string) *actionProgress {
+	return &actionProgress{
+		stackUid: stackUid,
+		out:      os.Stderr,
+		live:     term.IsANSI(os.Stderr),
+		started:  time.Now(),
+	}
+}
+
+// update takes the latest state of the action
+func (p *actionProgress) update(action cloud66.AsyncResult) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	first := !p.seen
+	p.action = action
+	p.seen = true
+	p.lock.Unlock()
+
+	if p.live {
+		if first {
+			p.stop = make(chan struct{})
+			p.drawn.Add(1)
+			go p.redraw()
+		}
+		return
+	}
+	if first {
+		p.event("started", action)
+	}
+	if action.FinishedAt == nil {
+		p.event("running", action)
+	}
+}
+
+// finish reports the end of the action, or of the wait with timedOut
+func (p *actionProgress) finish(action cloud66.AsyncResult, timedOut bool) {
+	if p == nil {
+		return
+	}
+	if p.live {
+		p.clear()
+		return
+	}
+	if timedOut {
+		p.event("timed_out", action)
+	} else {
+		p.event("finished", action)
+	}
+}
+
+// clear stops the live line and removes it
+func (p *actionProgress) clear() {
+	if p == nil || !p.live || p.stop == nil {
+		return
+	}
+	close(p.stop)
+	p.drawn.Wait()
+	p.stop = nil
+	fmt.Fprint(p.out, "\r\033[2K")
+}
+
+func (p *actionProgress) redraw() {
+	defer p.drawn.Done()
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for frame := 0; ; frame++ {
+		p.lock.Lock()
+		name := p.action.Action
+		p.lock.Unlock()
+		if name == "" {
+			name = "action"
+		}
+		elapsed := time.Since(p.started).Truncate(time.Second)
+		fmt.Fprintf(p.out, "\r\033[2K%s Waiting for %s to finish (%s)", progressFrames[frame%len(progressFrames)], name, elapsed)
+
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *actionProgress) event(name string, action cloud66.AsyncResult) {
+	status := actionStatus(action)
+	if name == "timed_out" {
+		status = "timed_out"
+	}
+	buf, err := json.Marshal(actionProgressEvent{
+		Event:    name,
+		ActionId: action.Id,
+		Action:   action.Action,
+		StackUid: p.stackUid,
+		Status:   status,
+		Elapsed:  time.Since(p.started).Truncate(time.Second).Seconds(),
+		Message:  action.FinishedMessage,
+		Time:     time.Now(),
+	})
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(p.out, string(buf))
+}
+
+// waitSettings returns the check frequency and the timeout of a wait, with the ones given with
+// --poll-interval and --timeout in place of the defaults of the command
+func waitSettings(checkFrequency time.Duration, timeout time.Duration) (time.Duration, time.Duration) {
+	if flagPollInterval > 0 {
+		checkFrequency = flagPollInterval
+	}
+	if flagTimeout > 0 {
+		timeout = flagTimeout
+	}
+	return checkFrequency, timeout
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66/cli"
+)
+
+const (
+	// actionCheckFrequency is how often cx actions wait checks the actions
+	actionCheckFrequency = 5 * time.Second
+	// actionTimeout is how long cx actions wait waits for the actions to finish
+	actionTimeout = 20 * time.Minute
+)
+
+var errActionsTimedOut = errors.New("timed out")
+
+// errActionNotWaited is returned by waitStackAction with --no-wait
+var errActionNotWaited = errors.New("the action was not waited for")
+
+// flagNoWait is set from the global --no-wait flag
+var flagNoWait bool
+
+var cmdActions = &Command{
+	Name:       "actions",
+	Build:      buildActions,
+	Short:      "commands to work with the actions (asynchronous operations) of a stack",
+	NeedsStack: true,
+	NeedsOrg:   false,
+}
+
+func buildActions() cli.Command {
+	base := buildBasicCommand()
+	base.Subcommands = []cli.Command{
+		cli.Command{
+			Name:   "list",
+			Usage:  "lists the latest actions of a stack",
+			Action: runListActions,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "last",
+					Usage: "number of actions to list",
+					Value: 25,
+				},
+				cli.BoolFlag{
+					Name:  "running",
+					Usage: "only list the actions that haven't finished",
+				},
+			},
+			Description: `Lists the latest actions of a stack, like scaling a process or setting an environment variable.
+The actions endpoint of the API only reads the actions, so there is no way to cancel one once started.
+
+Examples:
+$ cx actions list -s mystack
+$ cx actions list -s mystack --running
+`,
+		},
+		cli.Command{
+			Name:   "show",
+			Usage:  "shows an action of a stack",
+			Action: runShowAction,
+			Description: `Shows an action of a stack and its result once finished.
+
+Examples:
+$ cx actions show -s mystack 1234
+`,
+		},
+		cli.Command{
+			Name:   "wait",
+			Usage:  "waits for actions of a stack to finish",
+			Action: runWaitActions,
+			Description: `Waits for the given actions of a stack to finish. It exits with 1 when any of them fails and
+with 3 when they don't finish in time (in 20 minutes, or the time given with the global --timeout).
+
+Commands that start an action, like 'cx processes scale', print the id of the action and return when
+the global --no-wait option is given. This waits for them.
+
+Examples:
+$ cx actions wait -s mystack 1234
+$ cx actions wait -s mystack 1234 1235 1236
+$ cx actions wait -s mystack $(cx --no-wait processes scale -s mystack --name worker 4 | tail -n 1)
+`,
+		},
+	}
+
+	return base
+}
+
+func runListActions(c *cli.Context) {
+	stack := mustStack(c)
+
+	actions, err := listActions(stack.Uid, c.Int("last"))
+	must(err)
+	if c.Bool("running") {
+		var running []cloud66.AsyncResult
+		for _, action := range actions {
+			if action.FinishedAt == nil {
+				running = append(running, action)
+			}
+		}
+		actions = running
+	}
+
+	if printStructured(actions) {
+		return
+	}
+	printActions(actions)
+}
+
+func runShowAction(c *cli.Context) {
+	ids := actionIds(c)
+	if len(ids) != 1 {
+		cli.ShowSubcommandHelp(c)
+		os.Exit(2)
+	}
+	stack := mustStack(c)
+
+	action, err := getAction(stack.Uid, ids[0])
+	must(err)
+	if printStructured(action) {
+		return
+	}
+	printActions([]cloud66.AsyncResult{*action})
+	if action.FinishedMessage != "" {
+		fmt.Printf("\n%s\n", action.FinishedMessage)
+	}
+}
+
+func runWaitActions(c *cli.Context) {
+	ids := actionIds(c)
+	if len(ids) == 0 {
+		cli.ShowSubcommandHelp(c)
+		os.Exit(2)
+	}
+	stack := mustStack(c)
+
+	checkFrequency, timeout := waitSettings(actionCheckFrequency, actionTimeout)
+	actions, err := waitActions(stack.Uid, ids, checkFrequency, timeout)
+	// the exit codes are the same as the ones of redeploy --wait
+	if err == errActionsTimedOut {
+		printError("The actions didn't finish in %s", timeout)
+		os.Exit(redeployTimedOut)
+	}
+	must(err)
+
+	if !printStructured(actions) {
+		printActions(actions)
+	}
+	for _, action := range actions {
+		if !actionSucceeded(action) {
+			os.Exit(redeployFailed)
+		}
+	}
+}
+
+func actionIds(c *cli.Context) []int {
+	var ids []int
+	for _, arg := range c.Args() {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			printFatal("Invalid action Id %s", arg)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func printActions(actions []cloud66.AsyncResult) {
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+	defer w.Flush()
+
+	listRec(w, "ID", "ACTION", "RESOURCE", "USER", "STARTED", "FINISHED", "STATUS")
+	for _, action := range actions {
+		finished := ""
+		if action.FinishedAt != nil {
+			finished = prettyTime{*action.FinishedAt}.String()
+		}
+		listRec(w,
+			action.Id,
+			action.Action,
+			action.ResourceType+" "+action.ResourceId,
+			action.User,
+			prettyTime{action.StartedAt},
+			finished,
+			actionStatus(action),
+		)
+	}
+}
+
+func actionStatus(action cloud66.AsyncResult) string {
+	switch {
+	case action.FinishedAt == nil:
+		return "running"
+	case actionSucceeded(action):
+		return "success"
+	default:
+		return "failed"
+	}
+}
+
+func actionSucceeded(action cloud66.AsyncResult) bool {
+	return action.FinishedAt != nil && action.FinishedSuccess != nil && *action.FinishedSuccess
+}
+
+// listActions returns the last actions of a stack, the latest first
+func listActions(stackUid string, last int) ([]cloud66.AsyncResult, error) {
+	queryStrings := map[string]string{"page": "1"}
+
+	var result []cloud66.AsyncResult
+	for len(result) < last {
+		var p cloud66.Pagination
+		var actions []cloud66.AsyncResult
+		if err := client.Get(&actions, "/stacks/"+stackUid+"/actions.json", queryStrings, &p); err != nil {
+			return nil, err
+		}
+		result = append(result, actions...)
+		if p.Current < p.Next {
+			queryStrings["page"] = strconv.Itoa(p.Next)
+		} else {
+			break
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].StartedAt.After(result[j].StartedAt) })
+	if len(result) > last {
+		result = result[:last]
+	}
+	return result, nil
+}
+
+func getAction(stackUid string, id int) (*cloud66.AsyncResult, error) {
+	var action *cloud66.AsyncResult
+	if err := client.Get(&action, fmt.Sprintf("/stacks/%s/actions/%d.json", stackUid, id), nil, nil); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
+
+// waitActions checks the actions every interval until they have all finished and returns them
+// then, in the same order as ids
+func waitActions(stackUid string, ids []int, interval time.Duration, timeout time.Duration) ([]cloud66.AsyncResult, error) {
+	deadline := time.Now().Add(timeout)
+	actions := make([]cloud66.AsyncResult, len(ids))
+	finished := make([]bool, len(ids))
+	for {
+		remaining := 0
+		for idx, id := range ids {
+			if finished[idx] {
+				continue
+			}
+			action, err := getAction(stackUid, id)
+			if err != nil {
+				return nil, err
+			}
+			actions[idx] = *action
+			if action.FinishedAt == nil {
+				remaining++
+				continue
+			}
+			finished[idx] = true
+			if len(ids) > 1 {
+				fmt.Fprintf(os.Stderr, "Action %d (%s) finished: %s\n", action.Id, action.Action, actionStatus(*action))
+			}
+		}
+		if remaining == 0 {
+			return actions, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, errActionsTimedOut
+		}
+		time.Sleep(interval)
+	}
+}
+
+// waitStackAction waits for an action started by a command. With --no-wait it returns
+// errActionNotWaited instead, so the command can show the id with actionNotWaited
+func waitStackAction(asyncId int, stackUid string, checkFrequency time.Duration, timeout time.Duration, showProgress bool) (*cloud66.GenericResponse, error) {
+	if flagNoWait {
+		return nil, errActionNotWaited
+	}
+	return waitAction(asyncId, stackUid, checkFrequency, timeout, showProgress)
+}
+
+// actionNotWaited prints the id of the action when err is errActionNotWaited, so it can be waited
+// for with cx actions wait
+func actionNotWaited(err error, asyncId int) bool {
+	if err != errActionNotWaited {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "Action %d started. Use 'cx actions wait %d' on the same stack to wait for it\n", asyncId, asyncId)
+	fmt.Println(asyncId)
+	return true
+}
+
+// waitAction checks an action every checkFrequency until it has finished. --poll-interval and
+// --timeout replace the check frequency and the timeout when given
+func waitAction(asyncId int, stackUid string, checkFrequency time.Duration, timeout time.Duration, showProgress bool) (*cloud66.GenericResponse, error) {
+	checkFrequency, timeout = waitSettings(checkFrequency, timeout)
+
+	var progress *actionProgress
+	if showProgress {
+		progress = newActionProgress(stackUid)
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		action, err := getAction(stackUid, asyncId)
+		if err != nil {
+			progress.clear()
+			return nil, err
+		}
+		progress.update(*action)
+		if action.FinishedAt != nil {
+			progress.finish(*action, false)
+			return &cloud66.GenericResponse{Status: actionSucceeded(*action), Message: action.FinishedMessage}, nil
+		}
+		if time.Now().After(deadline) {
+			progress.finish(*action, true)
+			return nil, fmt.Errorf("timed-out after %s", timeout)
+		}
+		time.Sleep(checkFrequency)
+	}
+}
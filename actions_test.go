@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Actions", func() {
+	It("should tell running, successful and failed actions apart", func() {
+		now := time.Now()
+		succeeded, failed := true, false
+
+		Expect(actionStatus(cloud66.AsyncResult{StartedAt: now})).To(Equal("running"))
+		Expect(actionStatus(cloud66.AsyncResult{StartedAt: now, FinishedAt: &now, FinishedSuccess: &succeeded})).To(Equal("success"))
+		Expect(actionStatus(cloud66.AsyncResult{StartedAt: now, FinishedAt: &now, FinishedSuccess: &failed})).To(Equal("failed"))
+		Expect(actionStatus(cloud66.AsyncResult{StartedAt: now, FinishedAt: &now})).To(Equal("failed"))
+	})
+
+	It("should use --timeout and --poll-interval in place of the defaults", func() {
+		defer func() { flagTimeout, flagPollInterval = 0, 0 }()
+
+		checkFrequency, timeout := waitSettings(5*time.Second, 20*time.Minute)
+		Expect(checkFrequency).To(Equal(5 * time.Second))
+		Expect(timeout).To(Equal(20 * time.Minute))
+
+		flagTimeout, flagPollInterval = time.Hour, 30*time.Second
+		checkFrequency, timeout = waitSettings(5*time.Second, 20*time.Minute)
+		Expect(checkFrequency).To(Equal(30 * time.Second))
+		Expect(timeout).To(Equal(time.Hour))
+	})
+
+	It("should write progress events when not on a terminal", func() {
+		var out bytes.Buffer
+		progress := &actionProgress{stackUid: "abc", out: &out, started: time.Now()}
+		now := time.Now()
+		succeeded := true
+
+		progress.update(cloud66.AsyncResult{Id: 12, Action: "scale_process"})
+		progress.update(cloud66.AsyncResult{Id: 12, Action: "scale_process"})
+		finished := cloud66.AsyncResult{Id: 12, Action: "scale_process", FinishedAt: &now, FinishedSuccess: &succeeded}
+		progress.update(finished)
+		progress.finish(finished, false)
+
+		var events []string
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			var event actionProgressEvent
+			Expect(json.Unmarshal([]byte(line), &event)).To(Succeed())
+			Expect(event.ActionId).To(Equal(12))
+			Expect(event.StackUid).To(Equal("abc"))
+			events = append(events, event.Event+":"+event.Status)
+		}
+		Expect(events).To(Equal([]string{"started:running", "running:running", "running:running", "finished:success"}))
+	})
+})
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66-oss/cx/term"
+)
+
+const (
+	// segmentAttempts is how many times a segment is tried before giving up
+	segmentAttempts = 8
+	// segmentMaxBackoff is the longest wait between two attempts
+	segmentMaxBackoff = 30 * time.Second
+)
+
+var contentRangePattern = regexp.MustCompile(`^bytes (?:(\d+)-\d+|\*)/(\d+)$`)
+
+// backupSegment is a file of a backup being downloaded
+type backupSegment struct {
+	index cloud66.BackupSegmentIndex
+	file  string
+
+	// size is 0 until the server tells it
+	size       int64
+	downloaded int64
+	done       bool
+}
+
+// segmentDownloads downloads the segments of a backup and shows the progress
+type segmentDownloads struct {
+	stackUid string
+	backupId int
+	segments []*backupSegment
+
+	lock    sync.Mutex
+	started time.Time
+	live    bool
+}
+
+// downloadBackupSegments downloads the segments of a backup to dir, parallel at a time. Segments
+// that are already there (ie. from an interrupted download) are resumed. It returns the files
+// in the order of the segments once they are all downloaded and verified
+func downloadBackupSegments(stackUid string, backupId int, indeces []cloud66.BackupSegmentIndex, dir string, parallel int) ([]string, error) {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	downloads := &segmentDownloads{
+		stackUid: stackUid,
+		backupId: backupId,
+		started:  time.Now(),
+		live:     term.IsANSI(os.Stderr),
+	}
+	var files []string
+	for _, index := range indeces {
+		file := filepath.Join(dir, index.Filename)
+		downloads.segments = append(downloads.segments, &backupSegment{index: index, file: file})
+		files = append(files, file)
+	}
+
+	stop := make(chan struct{})
+	var drawn sync.WaitGroup
+	if downloads.live {
+		drawn.Add(1)
+		go func() {
+			defer drawn.Done()
+			ticker := time.NewTicker(250 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					downloads.draw()
+					fmt.Fprintln(os.Stderr)
+					return
+				case <-ticker.C:
+					downloads.draw()
+				}
+			}
+		}()
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallel)
+	errs := make([]error, len(downloads.segments))
+	for idx, segment := range downloads.segments {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(idx int, segment *backupSegment) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			errs[idx] = downloads.download(segment)
+		}(idx, segment)
+	}
+	wg.Wait()
+	close(stop)
+	drawn.Wait()
+
+	for idx, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("unable to download %s: %s", downloads.segments[idx].index.Filename, err)
+		}
+	}
+	return files, nil
+}
+
+// download tries the segment until it is downloaded and verified, waiting longer after
+// each failure
+func (d *segmentDownloads) download(segment *backupSegment) error {
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err := d.fetch(segment)
+		if err == nil {
+			d.lock.Lock()
+			segment.done = true
+			d.lock.Unlock()
+			if !d.live {
+				fmt.Fprintf(os.Stderr, "Downloaded %s\n", segment.index.Filename)
+			}
+			return nil
+		}
+		if attempt == segmentAttempts {
+			return err
+		}
+
+		d.printf("%s: %s. Retrying in %s", segment.index.Filename, err, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > segmentMaxBackoff {
+			backoff = segmentMaxBackoff
+		}
+	}
+}
+
+// fetch downloads the rest of the segment and verifies it
+func (d *segmentDownloads) fetch(segment *backupSegment) error {
+	var offset int64
+	if info, err := os.Stat(segment.file); err == nil {
+		offset = info.Size()
+	}
+
+	resp, err := requestSegment(d.stackUid, d.backupId, segment.index, offset)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	var size int64
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// starting over
+		flags |= os.O_TRUNC
+		offset = 0
+		size = resp.ContentLength
+	case http.StatusPartialContent:
+		start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			os.Remove(segment.file)
+			return fmt.Errorf("unexpected range %s", resp.Header.Get("Content-Range"))
+		}
+		size = total
+	case http.StatusRequestedRangeNotSatisfiable:
+		_, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || total != offset {
+			// the file on disk is not a part of this segment
+			os.Remove(segment.file)
+			return fmt.Errorf("the partial download doesn't match the segment")
+		}
+		size = total
+	default:
+		return fmt.Errorf("the server returned %s", resp.Status)
+	}
+
+	checksum := segmentChecksum(resp.Header)
+	if checksum != "" {
+		// kept for when the download is resumed from an interrupted run
+		ioutil.WriteFile(segment.file+".md5", []byte(checksum), 0644)
+	} else if buf, err := ioutil.ReadFile(segment.file + ".md5"); err == nil {
+		checksum = string(buf)
+	}
+
+	d.lock.Lock()
+	segment.size = size
+	segment.downloaded = offset
+	d.lock.Unlock()
+
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		out, err := os.OpenFile(segment.file, flags, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, &progressReader{reader: resp.Body, segment: segment, lock: &d.lock})
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return verifySegment(segment.file, size, checksum)
+}
+
+// requestSegment starts the download of a segment from offset
+func requestSegment(stackUid string, backupId int, index cloud66.BackupSegmentIndex, offset int64) (*http.Response, error) {
+	// the links expire so a new one is needed for each attempt
+	link, err := client.GetBackupSegment(stackUid, backupId, index.Extension)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", link.Url, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the sizes and checksums are of the stored file
+	req.Header.Set("Accept-Encoding", "identity")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// verifySegment checks the size and the MD5 checksum of the file, when they are known, and
+// removes it if they don't match so it is downloaded again
+func verifySegment(file string, size int64, checksum string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if size > 0 && info.Size() != size {
+		return fmt.Errorf("incomplete download (%d of %d bytes)", info.Size(), size)
+	}
+	if checksum == "" {
+		return nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != checksum {
+		os.Remove(file)
+		os.Remove(file + ".md5")
+		return fmt.Errorf("checksum mismatch (expected %s, got %s)", checksum, sum)
+	}
+	return nil
+}
+
+// segmentChecksum returns the MD5 checksum of the stored file (in hex) if the storage
+// tells it. The ETag of S3 is the MD5 of the file unless it was uploaded in parts
+func segmentChecksum(header http.Header) string {
+	for _, hash := range strings.Split(header.Get("X-Goog-Hash"), ",") {
+		hash = strings.TrimSpace(hash)
+		if strings.HasPrefix(hash, "md5=") {
+			if sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(hash, "md5=")); err == nil {
+				return hex.EncodeToString(sum)
+			}
+		}
+	}
+	if md5Header := header.Get("Content-MD5"); md5Header != "" {
+		if sum, err := base64.StdEncoding.DecodeString(md5Header); err == nil && len(sum) == md5.Size {
+			return hex.EncodeToString(sum)
+		}
+	}
+	etag := strings.ToLower(strings.Trim(header.Get("ETag"), `"`))
+	if len(etag) == 2*md5.Size {
+		if _, err := hex.DecodeString(etag); err == nil {
+			return etag
+		}
+	}
+	return ""
+}
+
+// parseContentRange returns the start and the total size from a Content-Range header
+func parseContentRange(value string) (int64, int64, bool) {
+	match := contentRangePattern.FindStringSubmatch(value)
+	if match == nil {
+		return 0, 0, false
+	}
+	var start int64
+	if match[1] != "" {
+		start, _ = strconv.ParseInt(match[1], 10, 64)
+	}
+	total, err := strconv.ParseInt(match[2], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, total, true
+}
+
+func (d *segmentDownloads) printf(format string, args ...interface{}) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.live {
+		fmt.Fprint(os.Stderr, "\r\033[2K")
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
+
+// draw shows a progress bar of all the segments
+func (d *segmentDownloads) draw() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	// the sizes are only known once the segments have started so each one counts the same
+	var downloaded int64
+	done := 0
+	ratio := 0.0
+	for _, segment := range d.segments {
+		downloaded += segment.downloaded
+		if segment.done {
+			done++
+			ratio++
+		} else if segment.size > 0 {
+			ratio += float64(segment.downloaded) / float64(segment.size)
+		}
+	}
+	ratio /= float64(len(d.segments))
+	if ratio > 1 {
+		ratio = 1
+	}
+
+	const width = 30
+	filled := int(ratio * width)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+
+	elapsed := time.Since(d.started).Seconds()
+	rate := ""
+	if elapsed > 0 {
+		rate = byteSize(int64(float64(downloaded)/elapsed)) + "/s"
+	}
+
+	fmt.Fprintf(os.Stderr, "\r\033[2K[%s] %3.0f%% %s %s  %d/%d segments",
+		bar, ratio*100, byteSize(downloaded), rate, done, len(d.segments))
+}
+
+func byteSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// progressReader counts the bytes read into the segment
+type progressReader struct {
+	reader  io.Reader
+	segment *backupSegment
+	lock    *sync.Mutex
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.lock.Lock()
+	r.segment.downloaded += int64(n)
+	r.lock.Unlock()
+	return n, err
+}
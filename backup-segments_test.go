@@ -0,0 +1,73 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backup segments", func() {
+	It("should read the range of a partial download", func() {
+		start, total, ok := parseContentRange("bytes 100-199/200")
+		Expect(ok).To(BeTrue())
+		Expect(start).To(Equal(int64(100)))
+		Expect(total).To(Equal(int64(200)))
+
+		_, total, ok = parseContentRange("bytes */200")
+		Expect(ok).To(BeTrue())
+		Expect(total).To(Equal(int64(200)))
+
+		_, _, ok = parseContentRange("bytes 100-199/*")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should only take the checksums that are the MD5 of the file", func() {
+		Expect(segmentChecksum(http.Header{"Etag": {`"5d41402abc4b2a76b9719d911017c592"`}})).To(Equal("5d41402abc4b2a76b9719d911017c592"))
+		Expect(segmentChecksum(http.Header{"Etag": {`"5d41402abc4b2a76b9719d911017c592-3"`}})).To(BeEmpty())
+		Expect(segmentChecksum(http.Header{"X-Goog-Hash": {"crc32c=n03x6A==,md5=XUFAKrxLKna5cZ2REBfFkg=="}})).To(Equal("5d41402abc4b2a76b9719d911017c592"))
+	})
+
+	It("should remove a segment that doesn't match its checksum", func() {
+		dir, err := ioutil.TempDir("", "segments")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		file := filepath.Join(dir, "backup.tar.gz-aa")
+		Expect(ioutil.WriteFile(file, []byte("hello"), 0644)).To(Succeed())
+		Expect(verifySegment(file, 5, "5d41402abc4b2a76b9719d911017c592")).To(Succeed())
+		Expect(verifySegment(file, 6, "")).NotTo(Succeed())
+
+		Expect(verifySegment(file, 5, "00000000000000000000000000000000")).NotTo(Succeed())
+		_, err = os.Stat(file)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("should stream the content of the gzipped files in the archive", func() {
+		var dump bytes.Buffer
+		gz := gzip.NewWriter(&dump)
+		gz.Write([]byte("CREATE TABLE users;\n"))
+		Expect(gz.Close()).To(Succeed())
+
+		var archive bytes.Buffer
+		tw := tar.NewWriter(&archive)
+		Expect(tw.WriteHeader(&tar.Header{Name: "backup/", Typeflag: tar.TypeDir, Mode: 0755})).To(Succeed())
+		Expect(tw.WriteHeader(&tar.Header{Name: "backup/db.sql.gz", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(dump.Len())})).To(Succeed())
+		tw.Write(dump.Bytes())
+		Expect(tw.Close()).To(Succeed())
+
+		var out bytes.Buffer
+		Expect(writeBackup(bytes.NewReader(archive.Bytes()), &out, true, true)).To(Succeed())
+		Expect(out.String()).To(Equal("CREATE TABLE users;\n"))
+
+		out.Reset()
+		Expect(writeBackup(bytes.NewReader(archive.Bytes()), &out, false, true)).To(Succeed())
+		Expect(out.Bytes()).To(Equal(archive.Bytes()))
+	})
+})
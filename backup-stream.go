@@ -0,0 +1,168 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+)
+
+// streamBackupSegments writes the segments of a backup to w one after the other as they
+// are downloaded, without keeping them on disk
+func streamBackupSegments(stackUid string, backupId int, indeces []cloud66.BackupSegmentIndex, w io.Writer) error {
+	for idx, index := range indeces {
+		fmt.Fprintf(os.Stderr, "Streaming %s (%d of %d)\n", index.Filename, idx+1, len(indeces))
+		if err := streamSegment(stackUid, backupId, index, w); err != nil {
+			return fmt.Errorf("unable to download %s: %s", index.Filename, err)
+		}
+	}
+	return nil
+}
+
+// segmentWriter keeps track of what was written of a segment so a failed download can be
+// resumed where it stopped, as what is written can't be taken back
+type segmentWriter struct {
+	w       io.Writer
+	hash    hash.Hash
+	written int64
+	// err is set when w fails, which is not worth retrying
+	err error
+}
+
+func (s *segmentWriter) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	s.hash.Write(p[:n])
+	s.written += int64(n)
+	if err != nil {
+		s.err = err
+	}
+	return n, err
+}
+
+func streamSegment(stackUid string, backupId int, index cloud66.BackupSegmentIndex, w io.Writer) error {
+	out := &segmentWriter{w: w, hash: md5.New()}
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		size, checksum, err := streamSegmentFrom(stackUid, backupId, index, out)
+		if err == nil {
+			if size > 0 && out.written != size {
+				return fmt.Errorf("incomplete download (%d of %d bytes)", out.written, size)
+			}
+			if sum := hex.EncodeToString(out.hash.Sum(nil)); checksum != "" && sum != checksum {
+				return fmt.Errorf("checksum mismatch (expected %s, got %s). The streamed backup is corrupt", checksum, sum)
+			}
+			return nil
+		}
+		if out.err != nil || attempt == segmentAttempts {
+			return err
+		}
+
+		printError("%s: %s. Retrying in %s", index.Filename, err, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > segmentMaxBackoff {
+			backoff = segmentMaxBackoff
+		}
+	}
+}
+
+// streamSegmentFrom writes the rest of the segment to out. It returns the size and the checksum
+// of the segment when the storage tells them
+func streamSegmentFrom(stackUid string, backupId int, index cloud66.BackupSegmentIndex, out *segmentWriter) (int64, string, error) {
+	offset := out.written
+	resp, err := requestSegment(stackUid, backupId, index, offset)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	var size int64
+	switch resp.StatusCode {
+	case http.StatusOK:
+		size = resp.ContentLength
+		// the range was ignored so skip what was already written
+		if _, err := io.CopyN(ioutil.Discard, resp.Body, offset); err != nil {
+			return 0, "", err
+		}
+	case http.StatusPartialContent:
+		start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			return 0, "", fmt.Errorf("unexpected range %s", resp.Header.Get("Content-Range"))
+		}
+		size = total
+	case http.StatusRequestedRangeNotSatisfiable:
+		_, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || total != offset {
+			return 0, "", fmt.Errorf("unexpected range %s", resp.Header.Get("Content-Range"))
+		}
+		return total, segmentChecksum(resp.Header), nil
+	default:
+		return 0, "", fmt.Errorf("the server returned %s", resp.Status)
+	}
+
+	_, err = io.Copy(out, resp.Body)
+	return size, segmentChecksum(resp.Header), err
+}
+
+// writeBackup copies the backup to w. With untar, the content of the files in the backup is
+// written instead of the archive, and with gunzip anything that is gzipped is decompressed
+func writeBackup(r io.Reader, w io.Writer, untar bool, gunzip bool) error {
+	if !untar {
+		if gunzip {
+			var err error
+			if r, err = gunzipped(r); err != nil {
+				return err
+			}
+		}
+		_, err := io.Copy(w, r)
+		return err
+	}
+
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !header.FileInfo().Mode().IsRegular() {
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "Extracting %s\n", header.Name)
+		var file io.Reader = archive
+		if gunzip {
+			if file, err = gunzipped(file); err != nil {
+				return fmt.Errorf("%s: %s", header.Name, err)
+			}
+		}
+		if _, err := io.Copy(w, file); err != nil {
+			return fmt.Errorf("%s: %s", header.Name, err)
+		}
+	}
+}
+
+// gunzipped decompresses r if it is gzipped
+func gunzipped(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(2)
+	if err == io.EOF || (err == nil && (magic[0] != 0x1f || magic[1] != 0x8b)) {
+		return buffered, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return gzip.NewReader(buffered)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66/cli"
+)
+
+// the successful states of a backup, as in the cloud66.BackupStatus, cloud66.VerifyStatus and
+// cloud66.RestoreStatus maps
+const (
+	backupOk  = 0 // BCK_OK
+	verifyOk  = 2 // VRF_OK
+	restoreOk = 2 // RST_OK
+)
+
+// backupCheck is the state of the backups of a database type on a stack
+type backupCheck struct {
+	Stack        string     `json:"stack"`
+	Environment  string     `json:"environment"`
+	DbType       string     `json:"db_type"`
+	LastBackup   *time.Time `json:"last_backup"`
+	LastVerified *time.Time `json:"last_verified"`
+	// Problem is empty when the backups are fine
+	Problem string `json:"problem"`
+}
+
+func runCheckBackups(c *cli.Context) {
+	maxAge, err := parseMaxAge(c.String("max-age"))
+	if err != nil {
+		printFatal(err.Error())
+	}
+	requireVerified := c.Bool("require-verified")
+
+	var stacks []cloud66.Stack
+	if c.Bool("all") {
+		if c.String("org") != "" {
+			org := mustOrg(c)
+			client.AccountId = &org.Id
+		}
+		if c.String("environment") != "" {
+			flagEnvironment = c.String("environment")
+		}
+		stacks, err = client.StackListWithFilter(filterByEnvironmentExact)
+		must(err)
+	} else {
+		stacks = []cloud66.Stack{*mustStack(c)}
+	}
+
+	var checks []backupCheck
+	now := time.Now()
+	for _, stack := range stacks {
+		backups, err := client.ManagedBackups(stack.Uid)
+		must(err)
+		tasks, err := listBackupTasks(stack.Uid)
+		must(err)
+		checks = append(checks, checkBackups(stack, backups, tasks, maxAge, requireVerified, now)...)
+	}
+
+	failed := 0
+	for _, check := range checks {
+		if check.Problem != "" {
+			failed++
+		}
+	}
+
+	if !printStructured(checks) {
+		printBackupChecks(checks, now)
+		if failed > 0 {
+			fmt.Printf("\n%d of %d databases don't have a good backup\n", failed, len(checks))
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func printBackupChecks(checks []backupCheck, now time.Time) {
+	if len(checks) == 0 {
+		fmt.Println("No backups or backup tasks found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+	defer w.Flush()
+
+	listRec(w, "STACK", "ENVIRONMENT", "DATABASE", "LAST BACKUP", "LAST VERIFIED", "STATUS")
+	for _, check := range checks {
+		status := "OK"
+		if check.Problem != "" {
+			status = "FAIL: " + check.Problem
+		}
+		listRec(w,
+			check.Stack,
+			check.Environment,
+			check.DbType,
+			backupAge(check.LastBackup, now),
+			backupAge(check.LastVerified, now),
+			status,
+		)
+	}
+}
+
+func backupAge(t *time.Time, now time.Time) string {
+	if t == nil {
+		return "never"
+	}
+	return now.Sub(*t).Truncate(time.Minute).String() + " ago"
+}
+
+// checkBackups checks that each database type with backups or a backup task on the stack has a
+// successful backup that is not older than maxAge and, with requireVerified, that it is verified
+func checkBackups(stack cloud66.Stack, backups []cloud66.ManagedBackup, tasks []backupTask, maxAge time.Duration, requireVerified bool, now time.Time) []backupCheck {
+	checks := map[string]*backupCheck{}
+	check := func(dbType string) *backupCheck {
+		key := strings.ToLower(dbType)
+		if checks[key] == nil {
+			checks[key] = &backupCheck{Stack: stack.Name, Environment: stack.Environment, DbType: dbType}
+		}
+		return checks[key]
+	}
+
+	for _, task := range tasks {
+		check(task.DbType)
+	}
+	for _, backup := range backups {
+		result := check(backup.DbType)
+		if backup.BackupStatus != backupOk {
+			continue
+		}
+		backupDate := backup.BackupDate
+		if result.LastBackup == nil || backupDate.After(*result.LastBackup) {
+			result.LastBackup = &backupDate
+		}
+		// a successful restore proves the backup as much as a verification
+		if backup.VerifyStatus == verifyOk || backup.RestoreStatus == restoreOk {
+			if result.LastVerified == nil || backupDate.After(*result.LastVerified) {
+				result.LastVerified = &backupDate
+			}
+		}
+	}
+
+	var result []backupCheck
+	for _, check := range checks {
+		switch {
+		case check.LastBackup == nil:
+			check.Problem = "no successful backup"
+		case now.Sub(*check.LastBackup) > maxAge:
+			check.Problem = fmt.Sprintf("no successful backup in the last %s", maxAge)
+		case requireVerified && check.LastVerified == nil:
+			check.Problem = "no verified backup"
+		case requireVerified && now.Sub(*check.LastVerified) > maxAge:
+			check.Problem = fmt.Sprintf("no verified backup in the last %s", maxAge)
+		}
+		result = append(result, *check)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].DbType < result[j].DbType })
+	return result
+}
+
+// parseMaxAge takes a duration like 2h or 90m, or a number of days like 2d
+func parseMaxAge(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && days > 0 {
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	} else if duration, err := time.ParseDuration(value); err == nil && duration > 0 {
+		return duration, nil
+	}
+	return 0, fmt.Errorf("invalid --max-age %s. Use a duration like 2h, 90m or 2d", value)
+}
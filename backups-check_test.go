@@ -0,0 +1,51 @@
+package main
+
+import (
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backups check", func() {
+	stack := cloud66.Stack{Uid: "abc", Name: "mystack", Environment: "production"}
+	now := time.Date(2020, 3, 27, 14, 30, 0, 0, time.UTC)
+	backups := []cloud66.ManagedBackup{
+		{Id: 1, DbType: "postgresql", BackupDate: now.Add(-90 * time.Minute), BackupStatus: 0},
+		{Id: 2, DbType: "postgresql", BackupDate: now.Add(-26 * time.Hour), BackupStatus: 0, VerifyStatus: 2},
+		{Id: 3, DbType: "redis", BackupDate: now.Add(-30 * time.Minute), BackupStatus: 1},
+	}
+	tasks := []backupTask{{Id: 12, DbType: "postgresql"}, {Id: 13, DbType: "mysql"}}
+
+	It("should fail the databases without a recent successful backup", func() {
+		checks := checkBackups(stack, backups, tasks, 2*time.Hour, false, now)
+		Expect(checks).To(HaveLen(3))
+
+		Expect(checks[0].DbType).To(Equal("mysql"))
+		Expect(checks[0].Problem).To(Equal("no successful backup"))
+		Expect(checks[1].DbType).To(Equal("postgresql"))
+		Expect(checks[1].Problem).To(BeEmpty())
+		Expect(*checks[1].LastBackup).To(Equal(now.Add(-90 * time.Minute)))
+		Expect(checks[2].DbType).To(Equal("redis"))
+		Expect(checks[2].Problem).To(Equal("no successful backup"))
+
+		checks = checkBackups(stack, backups, tasks, time.Hour, false, now)
+		Expect(checks[1].Problem).To(Equal("no successful backup in the last 1h0m0s"))
+	})
+
+	It("should require a recent verified backup when asked to", func() {
+		checks := checkBackups(stack, backups, tasks, 2*time.Hour, true, now)
+		Expect(checks[1].Problem).To(Equal("no verified backup in the last 2h0m0s"))
+
+		checks = checkBackups(stack, backups, tasks, 2*24*time.Hour, true, now)
+		Expect(checks[1].Problem).To(BeEmpty())
+	})
+
+	It("should take durations and days as the max age", func() {
+		Expect(parseMaxAge("90m")).To(Equal(90 * time.Minute))
+		Expect(parseMaxAge("2d")).To(Equal(48 * time.Hour))
+		_, err := parseMaxAge("2w")
+		Expect(err).To(HaveOccurred())
+	})
+})
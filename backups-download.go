@@ -2,10 +2,14 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
 
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66-oss/cx/term"
 	"github.com/cloud66/cli"
 )
 
@@ -28,6 +32,14 @@ func runDownloadBackup(c *cli.Context) {
 		printFatal("Cannot find file segments associated with this backup")
 	}
 
+	if c.Bool("stdout") {
+		streamBackup(stack.Uid, backupId, segmentIndeces, c.Bool("untar"), c.Bool("gunzip"))
+		return
+	}
+	if c.Bool("untar") || c.Bool("gunzip") {
+		printFatal("--untar and --gunzip can only be used with --stdout")
+	}
+
 	flagDownloadDir := c.String("directory")
 
 	mainDir := filepath.Join(homePath(), "cx_backups")
@@ -40,19 +52,10 @@ func runDownloadBackup(c *cli.Context) {
 	err = os.MkdirAll(dir, 0777)
 	must(err)
 
-	var files = []string{}
-	for _, segmentIndex := range segmentIndeces {
-
-		segment, err := client.GetBackupSegment(stack.Uid, backupId, segmentIndex.Extension)
-		must(err)
-
-		fmt.Printf("Downloading %s to %s\n", segmentIndex.Filename, dir)
-		// this should be moved to go routines
-		toFile := filepath.Join(dir, segmentIndex.Filename)
-		err = downloadFile(segment.Url, toFile)
-		must(err)
-
-		files = append(files, toFile)
+	fmt.Printf("Downloading %d segments to %s\n", len(segmentIndeces), dir)
+	files, err := downloadBackupSegments(stack.Uid, backupId, segmentIndeces, dir, c.Int("parallel"))
+	if err != nil {
+		printFatal("%s. Run the command again to resume the download", err.Error())
 	}
 
 	toFile := filepath.Join(mainDir, "backup_"+c.Args()[0]+".tar")
@@ -70,3 +73,33 @@ func runDownloadBackup(c *cli.Context) {
 	}
 	fmt.Println("Done")
 }
+
+// streamBackup writes the backup to stdout as it is downloaded, so it can be piped to a restore
+// without the disk space for it
+func streamBackup(stackUid string, backupId int, segmentIndeces []cloud66.BackupSegmentIndex, untar bool, gunzip bool) {
+	if term.IsANSI(os.Stdout) {
+		printFatal("Refusing to write the backup to the terminal. Pipe it to a command or redirect it to a file")
+	}
+
+	reader, writer := io.Pipe()
+	streamed := make(chan error, 1)
+	go func() {
+		err := streamBackupSegments(stackUid, backupId, segmentIndeces, writer)
+		writer.CloseWithError(err)
+		streamed <- err
+	}()
+
+	err := writeBackup(reader, os.Stdout, untar, gunzip)
+	if err == nil {
+		// whatever is left after the end of the archive
+		_, err = io.Copy(ioutil.Discard, reader)
+	}
+	reader.CloseWithError(err)
+	if serr := <-streamed; serr != nil {
+		err = serr
+	}
+	if err != nil {
+		printFatal(err.Error())
+	}
+	fmt.Fprintln(os.Stderr, "Done")
+}
@@ -16,59 +16,18 @@ func runNewBackup(c *cli.Context) {
 		*flagDbTypes = c.String("dbtypes")
 	}
 
-	var flagFrequency *string
-
-	if c.IsSet("frequency") {
-		flagFrequency = new(string)
-		*flagFrequency = c.String("frequency")
-	}
-
-	var flagKeep *int
-
-	if c.IsSet("keep") {
-		flagKeep = new(int)
-		*flagKeep = c.Int("keep")
-	}
-
-	var flagGzip *bool
-
-	if c.IsSet("gzip") {
-		flagGzip = new(bool)
-		*flagGzip = c.Bool("gzip")
-	}
-
-	var flagExcludetables *string
-
-	if c.IsSet("exclude-tables") {
-		flagExcludetables = new(string)
-		*flagExcludetables = c.String("exclude-tables")
-	}
-
-	var flagRunonreplica *bool
-
-	if c.IsSet("run-on-replica") {
-		flagRunonreplica = new(bool)
-		*flagRunonreplica = c.Bool("run-on-replica")
+	params, err := backupTaskParamsFrom(c)
+	if err != nil {
+		printFatal(err.Error())
+		return
 	}
-
-	logicalBackup := new(bool)
-
-	if c.IsSet("backup-type") {
-		flagBackupType := c.String("backup-type")
-		if flagBackupType == "binary" {
-			*logicalBackup = false
-		} else if flagBackupType == "text" {
-			*logicalBackup = true
-		} else {
-			printFatal("Acceptable values for the 'backup-type' flag are 'binary' and 'text'. You have entered '%s'.", flagBackupType)
-			return
-		}
-	} else {
+	if params.LogicalBackup == nil {
 		// Default is 'text' backup
-		*logicalBackup = true
+		params.LogicalBackup = new(bool)
+		*params.LogicalBackup = true
 	}
 
-	err := client.NewBackup(stack.Uid, flagDbTypes, flagFrequency, flagKeep, flagGzip, flagExcludetables, flagRunonreplica, logicalBackup)
+	err = client.NewBackup(stack.Uid, flagDbTypes, params.Frequency, params.KeepCount, params.Gzip, params.ExcludeTables, params.RunOnReplica, params.LogicalBackup)
 
 	if err != nil {
 		printFatal("Error during backup creation: " + err.Error())
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66/cli"
+)
+
+// backupTask is the schedule of the backups of a database
+type backupTask struct {
+	Id             int       `json:"id"`
+	DbType         string    `json:"db_type"`
+	Frequency      string    `json:"frequency"`
+	KeepCount      int       `json:"keep_count"`
+	Gzip           bool      `json:"gzip"`
+	ExcludedTables string    `json:"excluded_tables"`
+	RunOnReplica   bool      `json:"run_on_replica_server"`
+	LogicalBackup  bool      `json:"logical_backup"`
+	CreatedAt      time.Time `json:"created_at_iso"`
+	UpdatedAt      time.Time `json:"updated_at_iso"`
+}
+
+// BackupType is text for logical backups and binary for the others
+func (t backupTask) BackupType() string {
+	if t.LogicalBackup {
+		return "text"
+	}
+	return "binary"
+}
+
+// backupTaskParams are the settings of a backup task. Only the ones that are set are changed
+type backupTaskParams struct {
+	Frequency     *string `json:"frequency,omitempty"`
+	KeepCount     *int    `json:"keep_count,omitempty"`
+	Gzip          *bool   `json:"gzip,omitempty"`
+	ExcludeTables *string `json:"excluded_tables,omitempty"`
+	RunOnReplica  *bool   `json:"run_on_replica_server,omitempty"`
+	LogicalBackup *bool   `json:"logical_backup,omitempty"`
+}
+
+// backupTaskFlags are the settings of a backup task shared by backups new and backups tasks update
+var backupTaskFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "frequency",
+		Usage: "Frequency of backup task in cron schedule format. Put cron string in double quotes i.e \"0 */2 * * *\" .  Default value is \"0 */1 * * *\" ",
+	},
+	cli.IntFlag{
+		Name:  "keep",
+		Usage: "Number of previous backups to keep. Default value is 100.",
+	},
+	cli.BoolFlag{
+		Name:  "gzip",
+		Usage: "Compress your backups with gzip. Default value is true.",
+	},
+	cli.StringFlag{
+		Name:  "exclude-tables",
+		Usage: "Tables that must be excluded from the backup.",
+	},
+	cli.BoolFlag{
+		Name:  "run-on-replica",
+		Usage: "Run backup task on replica server if available. Default value is true.",
+	},
+	cli.StringFlag{
+		Name:  "backup-type",
+		Usage: "Specify the type of backup to perform. Acceptable values are 'binary' and 'text'. Default value is 'text'.",
+	},
+}
+
+func buildBackupTasks() cli.Command {
+	stackFlags := []cli.Flag{
+		cli.StringFlag{
+			Name:  "stack,s",
+			Usage: "full or partial stack name. This can be omitted if the current directory is a stack directory",
+		},
+		cli.StringFlag{
+			Name:  "environment,e",
+			Usage: "full or partial environment name",
+		},
+	}
+
+	return cli.Command{
+		Name:  "tasks",
+		Usage: "commands to work with the backup tasks (schedules) of a stack",
+		Subcommands: []cli.Command{
+			cli.Command{
+				Name:   "list",
+				Action: runListBackupTasks,
+				Usage:  "lists the backup tasks of a stack",
+				Flags: append([]cli.Flag{
+					cli.StringFlag{
+						Name:  "dbtype",
+						Usage: "only list the tasks of this database type",
+					},
+				}, stackFlags...),
+				Description: `Lists the backup tasks of a stack with their schedule and settings. Use the global --output json
+option to get them for scripts.
+
+Examples:
+$ cx backups tasks list -s mystack
+ID   DATABASE    FREQUENCY     KEEP  GZIP  TYPE  REPLICA  EXCLUDED TABLES
+12   postgresql  0 */1 * * *   100   true  text  true
+13   redis       0 0 * * *     7     true  text  false
+
+$ cx --output json backups tasks list -s mystack --dbtype postgresql
+`,
+			},
+			cli.Command{
+				Name:   "update",
+				Action: runUpdateBackupTask,
+				Usage:  "changes the settings of a backup task",
+				Flags:  append(append([]cli.Flag{}, backupTaskFlags...), stackFlags...),
+				Description: `Changes the settings of a backup task. Only the settings given are changed. The flags are the
+same as the ones of 'cx backups new'.
+
+Examples:
+$ cx backups tasks update -s mystack 12 --frequency="0 */6 * * *" --keep=20
+$ cx backups tasks update -s mystack 12 --gzip=false
+`,
+			},
+			cli.Command{
+				Name:   "delete",
+				Action: runDeleteBackupTasks,
+				Usage:  "deletes backup tasks",
+				Flags: append([]cli.Flag{
+					cli.BoolFlag{
+						Name:  "y",
+						Usage: "answer yes to confirmations",
+					},
+				}, stackFlags...),
+				Description: `Deletes backup tasks so no more backups are taken on their schedule. The backups already taken
+are kept.
+
+Examples:
+$ cx backups tasks delete -s mystack 12
+$ cx backups tasks delete -s mystack -y 12 13
+`,
+			},
+		},
+	}
+}
+
+func runListBackupTasks(c *cli.Context) {
+	stack := mustStack(c)
+
+	tasks, err := listBackupTasks(stack.Uid)
+	must(err)
+
+	dbType := strings.ToLower(c.String("dbtype"))
+	var filtered []backupTask
+	for _, task := range tasks {
+		if dbType == "" || strings.ToLower(task.DbType) == dbType {
+			filtered = append(filtered, task)
+		}
+	}
+
+	if printStructured(filtered) {
+		return
+	}
+
+	if len(filtered) == 0 {
+		fmt.Println("No backup tasks.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+	defer w.Flush()
+
+	listRec(w, "ID", "DATABASE", "FREQUENCY", "KEEP", "GZIP", "TYPE", "REPLICA", "EXCLUDED TABLES")
+	for _, task := range filtered {
+		listRec(w,
+			task.Id,
+			task.DbType,
+			task.Frequency,
+			task.KeepCount,
+			task.Gzip,
+			task.BackupType(),
+			task.RunOnReplica,
+			task.ExcludedTables,
+		)
+	}
+}
+
+func runUpdateBackupTask(c *cli.Context) {
+	if len(c.Args()) != 1 {
+		cli.ShowSubcommandHelp(c)
+		os.Exit(2)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		printFatal("Invalid backup task Id %s", c.Args()[0])
+	}
+
+	stack := mustStack(c)
+
+	params, err := backupTaskParamsFrom(c)
+	if err != nil {
+		printFatal(err.Error())
+	}
+	if params == (backupTaskParams{}) {
+		printFatal("Nothing to change. Use --frequency, --keep, --gzip, --exclude-tables, --run-on-replica or --backup-type")
+	}
+
+	task, err := updateBackupTask(stack.Uid, id, params)
+	must(err)
+
+	if printStructured(task) {
+		return
+	}
+	fmt.Printf("Backup task %d updated\n", id)
+}
+
+func runDeleteBackupTasks(c *cli.Context) {
+	if len(c.Args()) == 0 {
+		cli.ShowSubcommandHelp(c)
+		os.Exit(2)
+	}
+
+	var ids []int
+	for _, arg := range c.Args() {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			printFatal("Invalid backup task Id %s", arg)
+		}
+		ids = append(ids, id)
+	}
+
+	stack := mustStack(c)
+
+	if !c.Bool("y") {
+		mustConfirm(fmt.Sprintf("This deletes %d backup tasks of %s and no more backups are taken on their schedule. Proceed? [yes/N]", len(ids), stack.Name), "yes")
+	}
+
+	for _, id := range ids {
+		if err := deleteBackupTask(stack.Uid, id); err != nil {
+			printFatal("Error deleting backup task %d: %s", id, err.Error())
+		}
+		fmt.Printf("Backup task %d deleted\n", id)
+	}
+}
+
+// backupTaskParamsFrom returns the settings given with the backupTaskFlags
+func backupTaskParamsFrom(c *cli.Context) (backupTaskParams, error) {
+	var params backupTaskParams
+	if c.IsSet("frequency") {
+		frequency := c.String("frequency")
+		params.Frequency = &frequency
+	}
+	if c.IsSet("keep") {
+		keep := c.Int("keep")
+		params.KeepCount = &keep
+	}
+	if c.IsSet("gzip") {
+		gzip := c.Bool("gzip")
+		params.Gzip = &gzip
+	}
+	if c.IsSet("exclude-tables") {
+		excludeTables := c.String("exclude-tables")
+		params.ExcludeTables = &excludeTables
+	}
+	if c.IsSet("run-on-replica") {
+		runOnReplica := c.Bool("run-on-replica")
+		params.RunOnReplica = &runOnReplica
+	}
+	if c.IsSet("backup-type") {
+		switch backupType := c.String("backup-type"); backupType {
+		case "binary", "text":
+			logical := backupType == "text"
+			params.LogicalBackup = &logical
+		default:
+			return params, fmt.Errorf("Acceptable values for the 'backup-type' flag are 'binary' and 'text'. You have entered '%s'.", backupType)
+		}
+	}
+	return params, nil
+}
+
+// The SDK only creates backup tasks, with NewBackup. listBackupTasks, updateBackupTask and
+// deleteBackupTask are the calls it is missing, to move to it
+
+func listBackupTasks(stackUid string) ([]backupTask, error) {
+	queryStrings := map[string]string{"page": "1"}
+
+	var result []backupTask
+	for {
+		var p cloud66.Pagination
+		var tasks []backupTask
+		if err := client.Get(&tasks, "/stacks/"+stackUid+"/backup_tasks.json", queryStrings, &p); err != nil {
+			return nil, err
+		}
+		result = append(result, tasks...)
+		if p.Current < p.Next {
+			queryStrings["page"] = strconv.Itoa(p.Next)
+		} else {
+			break
+		}
+	}
+	return result, nil
+}
+
+func updateBackupTask(stackUid string, id int, params backupTaskParams) (*backupTask, error) {
+	var task *backupTask
+	if err := client.Put(&task, fmt.Sprintf("/stacks/%s/backup_tasks/%d.json", stackUid, id), params); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func deleteBackupTask(stackUid string, id int) error {
+	return client.Delete(fmt.Sprintf("/stacks/%s/backup_tasks/%d.json", stackUid, id))
+}
@@ -65,23 +65,49 @@ Examples:
 		cli.Command{
 			Name:   "download",
 			Action: runDownloadBackup,
-			Usage:  "backups download [-d <download directory>] <backup Id>",
+			Usage:  "backups download [-d <download directory>] [--parallel <n>] [--stdout [--untar] [--gunzip]] <backup Id>",
 			Description: `This downloads a backup from the available backups of a stack. This is limited to a single database type.
-The command might download multiple files in parallel and concatenate and untar them if needed. The resulting file
-can be used to manually restore the database.
+The command downloads the files of the backup in parallel and concatenates them once they are all downloaded. The
+resulting file can be used to manually restore the database.
+
+Each file is verified against its size and checksum (when the storage provides one) and is retried a few times if it
+fails. If the download is interrupted, running the command again resumes it from where it stopped.
 
 -d allows you to set the directory used to download the backup. You need to have write permissions over that directory
 if no directory is specified, ~/cx_backups is used. If the directory does not exist, it will be created.
+--parallel sets how many files are downloaded at the same time (4 by default).
+
+--stdout writes the backup to stdout as it is downloaded instead of saving it, so it can be piped to a restore
+without needing disk space for it. --untar writes the content of the files in the backup instead of the archive
+and --gunzip decompresses what is gzipped.
 
 The caller needs to have admin rights over the stack.
 
 Examples:
 $ cx backups download -s mystack 123
+$ cx backups download -s mystack --stdout --untar --gunzip 123 | psql mydb
 `,
 			Flags: []cli.Flag{
 				cli.StringFlag{
 					Name: "directory,d",
 				},
+				cli.IntFlag{
+					Name:  "parallel",
+					Usage: "number of files to download at the same time",
+					Value: 4,
+				},
+				cli.BoolFlag{
+					Name:  "stdout",
+					Usage: "write the backup to stdout instead of a file",
+				},
+				cli.BoolFlag{
+					Name:  "untar",
+					Usage: "with --stdout, write the content of the files in the backup instead of the archive",
+				},
+				cli.BoolFlag{
+					Name:  "gunzip",
+					Usage: "with --stdout, decompress what is gzipped",
+				},
 			},
 		},
 		cli.Command{
@@ -92,34 +118,48 @@ $ cx backups download -s mystack 123
 
 Example:
 $ cx backups new -s mystack	--dbtypes=postgresql --frequency="0 */1 * * *" --gzip=true exclude-tables=my_log_table --run-on-replica=false --backup-type=text`,
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				cli.StringFlag{
 					Name:  "dbtypes",
 					Usage: "Comma separated list of Database types which need backup tasks i.e mysql,postgresql, ... . Default value is \"all\" ",
 				},
+			}, backupTaskFlags...),
+		},
+		buildBackupTasks(),
+		cli.Command{
+			Name:   "check",
+			Action: runCheckBackups,
+			Usage:  "checks that the databases have recent successful backups",
+			Description: `Checks that each database type of a stack has a successful backup that is not older than --max-age.
+With --require-verified the backup also needs to be verified (or restored successfully). The database types are
+taken from the backup tasks and the backups of the stack.
+
+The command shows a report and exits with 1 if any database doesn't have a good backup, so it can be run from
+cron or a CI job. Use --all to check all the stacks of the organization (or the ones in the environment given
+with -e) and the global --output json option to get the report for scripts.
+
+Examples:
+$ cx backups check -s mystack
+$ cx backups check -s mystack --max-age 2h --require-verified
+$ cx backups check --all --org acme -e production --max-age 1d
+`,
+			Flags: []cli.Flag{
 				cli.StringFlag{
-					Name:  "frequency",
-					Usage: "Frequency of backup task in cron schedule format. Put cron string in double quotes i.e \"0 */2 * * *\" .  Default value is \"0 */1 * * *\" ",
-				},
-				cli.IntFlag{
-					Name:  "keep",
-					Usage: "Number of previous backups to keep. Default value is 100.",
+					Name:  "max-age",
+					Usage: "the oldest a backup can be, like 2h, 90m or 2d",
+					Value: "24h",
 				},
 				cli.BoolFlag{
-					Name:  "gzip",
-					Usage: "Compress your backups with gzip. Default value is true.",
-				},
-				cli.StringFlag{
-					Name:  "exclude-tables",
-					Usage: "Tables that must be excluded from the backup.",
+					Name:  "require-verified",
+					Usage: "the backups need to be verified",
 				},
 				cli.BoolFlag{
-					Name:  "run-on-replica",
-					Usage: "Run backup task on replica server if available. Default value is true.",
+					Name:  "all",
+					Usage: "check all the stacks of the organization instead of one",
 				},
 				cli.StringFlag{
-					Name:  "backup-type",
-					Usage: "Specify the type of backup to perform. Acceptable values are 'binary' and 'text'. Default value is 'text'.",
+					Name:  "org",
+					Usage: "full or partial organization name, with --all",
 				},
 			},
 		},
@@ -164,11 +204,19 @@ func runBackups(c *cli.Context) {
 
 func printBackupList(w io.Writer, backups []cloud66.ManagedBackup, dbType string) {
 	sort.Sort(backupsByDate(backups))
+	var filteredBackups []cloud66.ManagedBackup
 	for _, a := range backups {
 		if dbType == "" || strings.ToLower(a.DbType) == strings.ToLower(dbType) {
-			listBackup(w, a)
+			filteredBackups = append(filteredBackups, a)
 		}
 	}
+	if printStructured(filteredBackups) {
+		return
+	}
+
+	for _, a := range filteredBackups {
+		listBackup(w, a)
+	}
 }
 
 func listBackup(w io.Writer, a cloud66.ManagedBackup) {
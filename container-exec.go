@@ -30,28 +30,7 @@ func runContainerExec(c *cli.Context) {
 		printFatal("Container with Id '" + containerUid + "' not found")
 	}
 
-	var serverUID string
-	if stack.Backend == "kubernetes" {
-		servers, err := client.Servers(stack.Uid)
-		must(err)
-
-		kubernetesMasterServerUID := ""
-		for _, server := range servers {
-			if server.IsKubernetesMaster {
-				kubernetesMasterServerUID = server.Uid
-			}
-		}
-
-		if kubernetesMasterServerUID == "" {
-			printFatal("Couldn't find a Kubernetes master server")
-		}
-
-		serverUID = kubernetesMasterServerUID
-	} else {
-		serverUID = container.ServerUid
-	}
-
-	server, err := client.GetServer(stack.Uid, serverUID, 0)
+	server, err := containerServer(*stack, *container)
 	must(err)
 
 	cliFlags := c.String("cli-flags")
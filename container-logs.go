@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66-oss/cx/term"
+	"github.com/cloud66/cli"
+	"github.com/mgutz/ansi"
+)
+
+// containerLogOptions are passed on to docker logs and kubectl logs
+type containerLogOptions struct {
+	Follow bool
+	Since  string
+	// Tail is the number of lines from the end of the logs. All the lines when negative
+	Tail int
+}
+
+var containerLogFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "follow,f",
+		Usage: "keep showing new log lines",
+	},
+	cli.StringFlag{
+		Name:  "since",
+		Usage: "only show the logs since this duration (ie. 15m) or time (ie. 2006-01-02T15:04:05Z)",
+	},
+	cli.IntFlag{
+		Name:  "tail,n",
+		Usage: "number of lines to show from the end of the logs. All of them by default",
+		Value: -1,
+	},
+}
+
+func containerLogOptionsFrom(c *cli.Context) containerLogOptions {
+	return containerLogOptions{
+		Follow: c.Bool("follow"),
+		Since:  c.String("since"),
+		Tail:   c.Int("tail"),
+	}
+}
+
+func runContainerLogs(c *cli.Context) {
+	if len(c.Args()) != 1 {
+		cli.ShowSubcommandHelp(c)
+		os.Exit(2)
+	}
+
+	stack := mustStack(c)
+
+	containerUid := c.Args()[0]
+	container, err := client.GetContainer(stack.Uid, containerUid)
+	must(err)
+
+	if container == nil {
+		printFatal("Container with Id '" + containerUid + "' not found")
+	}
+
+	if containerLogs(*stack, []cloud66.Container{*container}, containerLogOptionsFrom(c)) > 0 {
+		os.Exit(1)
+	}
+}
+
+// containerServer returns the server to run container commands on: the Kubernetes master for
+// Kubernetes stacks and the server of the container for the others
+func containerServer(stack cloud66.Stack, container cloud66.Container) (*cloud66.Server, error) {
+	serverUID := container.ServerUid
+	if stack.Backend == "kubernetes" {
+		servers, err := client.Servers(stack.Uid)
+		if err != nil {
+			return nil, err
+		}
+
+		serverUID = ""
+		for _, server := range servers {
+			if server.IsKubernetesMaster {
+				serverUID = server.Uid
+			}
+		}
+
+		if serverUID == "" {
+			return nil, fmt.Errorf("Couldn't find a Kubernetes master server")
+		}
+	}
+
+	return client.GetServer(stack.Uid, serverUID, 0)
+}
+
+// containerLogsCommand is the docker or kubectl command that shows the logs of the container
+func containerLogsCommand(stack cloud66.Stack, container cloud66.Container, options containerLogOptions) string {
+	var args []string
+	if options.Follow {
+		args = append(args, "--follow")
+	}
+	if options.Tail >= 0 {
+		args = append(args, fmt.Sprintf("--tail=%d", options.Tail))
+	}
+
+	if stack.Backend == "kubernetes" {
+		if options.Since != "" {
+			if _, err := time.ParseDuration(options.Since); err == nil {
+				args = append(args, "--since="+options.Since)
+			} else {
+				args = append(args, "--since-time="+options.Since)
+			}
+		}
+		return fmt.Sprintf("kubectl --namespace=%s logs %s %s", stack.Namespace(), strings.Join(args, " "), container.Uid)
+	}
+
+	if options.Since != "" {
+		args = append(args, "--since="+options.Since)
+	}
+	return fmt.Sprintf("sudo docker logs %s %s", strings.Join(args, " "), container.Uid)
+}
+
+// containerLogStream is the logs of the containers that are read from one server, over one connection
+type containerLogStream struct {
+	Server     cloud66.Server
+	Containers []cloud66.Container
+	// Prefixes go in front of the lines of each container, by container Uid
+	Prefixes map[string]string
+}
+
+// containerLogs shows the logs of all the containers, with the container name in front of each
+// line when there is more than one. It returns the number of containers it failed to read
+func containerLogs(stack cloud66.Stack, containers []cloud66.Container, options containerLogOptions) int {
+	streams, err := containerLogStreams(stack, containers, term.IsANSI(os.Stdout), func(container cloud66.Container) (*cloud66.Server, error) {
+		return containerServer(stack, container)
+	})
+	must(err)
+
+	var gatewayServers []cloud66.Server
+	for _, stream := range streams {
+		gatewayServers = append(gatewayServers, stream.Server)
+	}
+	must(openServerGateways(gatewayServers))
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	failed := 0
+	for _, stream := range streams {
+		wg.Add(1)
+		go func(stream containerLogStream) {
+			defer wg.Done()
+			errs := serverContainerLogs(stack, stream.Server, stream.Containers, options, stream.Prefixes, &lock)
+			// the ones stopped with Ctrl-C haven't failed
+			if isInterrupted() {
+				return
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			for _, err := range errs {
+				printError(err.Error())
+				failed++
+			}
+		}(stream)
+	}
+	wg.Wait()
+	closeServerLeases()
+	return failed
+}
+
+// containerLogStreams groups the containers by the server their logs are read from, so containers
+// on the same server share the lease and the connection. The logs of all the containers of a
+// Kubernetes stack are read from the master. serverOf finds the server of a container
+func containerLogStreams(stack cloud66.Stack, containers []cloud66.Container, colored bool, serverOf func(cloud66.Container) (*cloud66.Server, error)) ([]containerLogStream, error) {
+	prefixed := len(containers) > 1
+	width := 0
+	for _, container := range containers {
+		if len(container.Name) > width {
+			width = len(container.Name)
+		}
+	}
+
+	var streams []*containerLogStream
+	byServer := map[string]*containerLogStream{}
+	for idx, container := range containers {
+		stream, ok := byServer[container.ServerUid]
+		if stack.Backend == "kubernetes" && len(streams) > 0 {
+			// they all go through the master
+			stream, ok = streams[0], true
+		}
+		if !ok {
+			server, err := serverOf(container)
+			if err != nil {
+				return nil, err
+			}
+			if stream, ok = byServer[server.Uid]; !ok {
+				stream = &containerLogStream{Server: *server, Prefixes: map[string]string{}}
+				streams = append(streams, stream)
+				byServer[server.Uid] = stream
+			}
+		}
+
+		prefix := ""
+		if prefixed {
+			prefix = fmt.Sprintf("%-*s", width, container.Name)
+			if colored {
+				prefix = ansi.Color(prefix, tailColors[idx%len(tailColors)])
+			}
+			prefix += " | "
+		}
+		stream.Containers = append(stream.Containers, container)
+		stream.Prefixes[container.Uid] = prefix
+	}
+
+	result := make([]containerLogStream, len(streams))
+	for idx, stream := range streams {
+		result[idx] = *stream
+	}
+	return result, nil
+}
+
+func serverContainerLogs(stack cloud66.Stack, server cloud66.Server, containers []cloud66.Container, options containerLogOptions, prefixes map[string]string, lock *sync.Mutex) []error {
+	sshFile, err := prepareLocalSshKey(server)
+	if err != nil {
+		return []error{err}
+	}
+	// open the firewall. The leases are closed together once all the logs are read
+	if _, err := openServerLease(server, 2); err != nil {
+		return []error{err}
+	}
+
+	config, err := serverSshConfig(server, sshFile)
+	if err != nil {
+		return []error{err}
+	}
+	conn, err := dialServer(config)
+	if err != nil {
+		return []error{err}
+	}
+	defer conn.Close()
+
+	var errs []error
+	var errsLock sync.Mutex
+	var wg sync.WaitGroup
+	for _, container := range containers {
+		wg.Add(1)
+		go func(container cloud66.Container) {
+			defer wg.Done()
+
+			stdout := newPrefixWriter(os.Stdout, prefixes[container.Uid], lock)
+			stderr := newPrefixWriter(os.Stderr, prefixes[container.Uid], lock)
+			err := conn.Run(containerLogsCommand(stack, container, options), nil, stdout, stderr)
+			stdout.Flush()
+			stderr.Flush()
+			if err != nil {
+				errsLock.Lock()
+				errs = append(errs, fmt.Errorf("%s: %s", container.Name, err))
+				errsLock.Unlock()
+			}
+		}(container)
+	}
+	wg.Wait()
+	return errs
+}
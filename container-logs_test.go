@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66/cli"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Container logs", func() {
+	It("should read the options from the flags", func() {
+		flagSet := flag.NewFlagSet("test", 0)
+		flagSet.Bool("follow", true, "")
+		flagSet.String("since", "15m", "")
+		flagSet.Int("tail", 20, "")
+
+		options := containerLogOptionsFrom(cli.NewContext(nil, flagSet, nil))
+		Expect(options).To(Equal(containerLogOptions{Follow: true, Since: "15m", Tail: 20}))
+	})
+
+	Context("building the logs command", func() {
+		container := cloud66.Container{Uid: "2844142c"}
+
+		It("should use docker logs", func() {
+			stack := cloud66.Stack{Backend: "docker"}
+			Expect(containerLogsCommand(stack, container, containerLogOptions{Tail: -1})).To(Equal("sudo docker logs  2844142c"))
+			Expect(containerLogsCommand(stack, container, containerLogOptions{Follow: true, Since: "15m", Tail: 20})).
+				To(Equal("sudo docker logs --follow --tail=20 --since=15m 2844142c"))
+		})
+
+		It("should use kubectl logs on Kubernetes stacks", func() {
+			stack := cloud66.Stack{Backend: "kubernetes", Namespaces: []string{"mystack"}}
+			Expect(containerLogsCommand(stack, container, containerLogOptions{Since: "15m", Tail: -1})).
+				To(Equal("kubectl --namespace=mystack logs --since=15m 2844142c"))
+			Expect(containerLogsCommand(stack, container, containerLogOptions{Since: "2006-01-02T15:04:05Z", Tail: 0})).
+				To(Equal("kubectl --namespace=mystack logs --tail=0 --since-time=2006-01-02T15:04:05Z 2844142c"))
+		})
+	})
+
+	Context("grouping the containers by server", func() {
+		containers := []cloud66.Container{
+			{Uid: "a1", Name: "web-1", ServerUid: "lion"},
+			{Uid: "b1", Name: "worker-1", ServerUid: "tiger"},
+			{Uid: "a2", Name: "web-2", ServerUid: "lion"},
+		}
+		var lookups []string
+		serverOf := func(container cloud66.Container) (*cloud66.Server, error) {
+			lookups = append(lookups, container.Uid)
+			if container.ServerUid == "tiger" {
+				return &cloud66.Server{Uid: "tiger", Name: "tiger"}, nil
+			}
+			// the Kubernetes master
+			return &cloud66.Server{Uid: "lion", Name: "lion"}, nil
+		}
+
+		BeforeEach(func() {
+			lookups = nil
+		})
+
+		It("should read the containers on the same server over one stream", func() {
+			streams, err := containerLogStreams(cloud66.Stack{Backend: "docker"}, containers, false, serverOf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lookups).To(Equal([]string{"a1", "b1"}))
+			Expect(streams).To(HaveLen(2))
+			Expect(streams[0].Server.Uid).To(Equal("lion"))
+			Expect(streams[0].Containers).To(Equal([]cloud66.Container{containers[0], containers[2]}))
+			Expect(streams[0].Prefixes).To(Equal(map[string]string{"a1": "web-1    | ", "a2": "web-2    | "}))
+			Expect(streams[1].Server.Uid).To(Equal("tiger"))
+			Expect(streams[1].Prefixes).To(Equal(map[string]string{"b1": "worker-1 | "}))
+		})
+
+		It("should read all the containers of a Kubernetes stack from the master", func() {
+			streams, err := containerLogStreams(cloud66.Stack{Backend: "kubernetes"}, containers, false, serverOf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lookups).To(Equal([]string{"a1"}))
+			Expect(streams).To(HaveLen(1))
+			Expect(streams[0].Containers).To(Equal(containers))
+		})
+
+		It("should not prefix the lines of a single container", func() {
+			streams, err := containerLogStreams(cloud66.Stack{Backend: "docker"}, containers[:1], true, serverOf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(streams[0].Prefixes).To(Equal(map[string]string{"a1": ""}))
+		})
+	})
+})
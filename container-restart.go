@@ -33,6 +33,9 @@ func runContainerRestart(c *cli.Context) {
 		printFatal(err.Error())
 	}
 	genericRes, err := endServerSet(*asyncId, stack.Uid)
+	if actionNotWaited(err, *asyncId) {
+		return
+	}
 	if err != nil {
 		printFatal(err.Error())
 	}
@@ -49,5 +52,5 @@ func startContainerRestart(stackUid string, containerUid string) (*int, error) {
 }
 
 func endContainerRestart(asyncId int, stackUid string) (*cloud66.GenericResponse, error) {
-	return client.WaitStackAsyncAction(asyncId, stackUid, 3*time.Second, 20*time.Minute, true)
+	return waitStackAction(asyncId, stackUid, 3*time.Second, 20*time.Minute, true)
 }
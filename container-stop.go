@@ -33,6 +33,9 @@ func runContainerStop(c *cli.Context) {
 		printFatal(err.Error())
 	}
 	genericRes, err := endServerSet(*asyncId, stack.Uid)
+	if actionNotWaited(err, *asyncId) {
+		return
+	}
 	if err != nil {
 		printFatal(err.Error())
 	}
@@ -49,5 +52,5 @@ func startContainerStop(stackUid string, containerUid string) (*int, error) {
 }
 
 func endContainerStop(asyncId int, stackUid string) (*cloud66.GenericResponse, error) {
-	return client.WaitStackAsyncAction(asyncId, stackUid, 3*time.Second, 20*time.Minute, true)
+	return waitStackAction(asyncId, stackUid, 3*time.Second, 20*time.Minute, true)
 }
@@ -86,6 +86,21 @@ $ cx containers stop -s mystack web
 			Usage:       "[DEPRECATED]",
 			Description: `This command is deprecated. Please use: "cx run" instead`,
 		},
+		cli.Command{
+			Name:   "logs",
+			Action: runContainerLogs,
+			Flags:  containerLogFlags,
+			Usage:  "shows the logs of a container on the given stack",
+			Description: `Shows the output (stdout and stderr) of a container on the given stack by container Id.
+The logs are read with docker logs on the server of the container, or with kubectl logs on the
+Kubernetes master for Kubernetes stacks.
+
+Examples:
+$ cx containers logs -s mystack 2844142c
+$ cx containers logs -s mystack --tail 100 --follow 2844142c
+$ cx containers logs -s mystack --since 15m 2844142c
+`,
+		},
 		cli.Command{
 			Name:   "attach",
 			Action: runContainerAttach,
@@ -123,7 +138,9 @@ func runContainers(c *cli.Context) {
 		if server == nil {
 			printFatal("Server '" + flagServer + "' not found")
 		}
-		fmt.Printf("Server: %s\n", server.Name)
+		if !structuredOutput() {
+			fmt.Printf("Server: %s\n", server.Name)
+		}
 		serverUid = &server.Uid
 	}
 
@@ -134,6 +151,11 @@ func runContainers(c *cli.Context) {
 }
 
 func printContainerList(w io.Writer, containers []cloud66.Container, flagVerbose bool) {
+	sort.Sort(containersByService(containers))
+	if printStructured(containers) {
+		return
+	}
+
 	if flagVerbose {
 		listRec(w,
 			"SERVICE",
@@ -157,7 +179,6 @@ func printContainerList(w io.Writer, containers []cloud66.Container, flagVerbose
 			"HEALTH")
 	}
 
-	sort.Sort(containersByService(containers))
 	for _, a := range containers {
 		if a.Uid != "" {
 			listContainer(w, a, flagVerbose)
@@ -45,6 +45,9 @@ func runSlaveResync(c *cli.Context) {
 		printFatal(err.Error())
 	}
 	genericRes, err := endSlaveResync(*asyncId, stack.Uid)
+	if actionNotWaited(err, *asyncId) {
+		return
+	}
 	if err != nil {
 		printFatal(err.Error())
 	}
@@ -60,5 +63,5 @@ func startSlaveResync(stackUid string, serverUid string, dbType *string) (*int,
 }
 
 func endSlaveResync(asyncId int, stackUid string) (*cloud66.GenericResponse, error) {
-	return client.WaitStackAsyncAction(asyncId, stackUid, 5*time.Second, 2*time.Hour, true)
+	return waitStackAction(asyncId, stackUid, 5*time.Second, 2*time.Hour, true)
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var dotenvKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// dotenvVar is a variable of a .env file
+type dotenvVar struct {
+	Key   string
+	Value string
+}
+
+// parseDotenv reads the variables of a .env file, in order. Values can be unquoted, in single
+// quotes (taken as they are) or in double quotes (with \n, \t, \" and \\ escapes). Quoted values
+// can span many lines. A variable given twice keeps the last value
+func parseDotenv(content string) ([]dotenvVar, error) {
+	content = strings.Replace(content, "\r\n", "\n", -1)
+	content = strings.TrimPrefix(content, "\ufeff")
+
+	var vars []dotenvVar
+	index := map[string]int{}
+	line := 1
+	for len(content) > 0 {
+		var current string
+		current, content = cutLine(content)
+		start := line
+		line++
+
+		trimmed := strings.TrimSpace(current)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		trimmed = strings.TrimPrefix(trimmed, "export ")
+
+		eq := strings.Index(trimmed, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=value", start)
+		}
+		key := strings.TrimSpace(trimmed[:eq])
+		if !dotenvKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("line %d: invalid key %q", start, key)
+		}
+		raw := strings.TrimLeft(trimmed[eq+1:], " \t")
+
+		var value string
+		if len(raw) > 0 && (raw[0] == '"' || raw[0] == '\'') {
+			quote := raw[0]
+			rest := raw[1:]
+			// the value goes on until the closing quote, maybe on a later line
+			for {
+				end := closingQuote(rest, quote)
+				if end >= 0 {
+					if after := strings.TrimSpace(rest[end+1:]); after != "" && !strings.HasPrefix(after, "#") {
+						return nil, fmt.Errorf("line %d: unexpected %q after the value of %s", line-1, after, key)
+					}
+					rest = rest[:end]
+					break
+				}
+				if len(content) == 0 {
+					return nil, fmt.Errorf("line %d: missing closing quote for %s", start, key)
+				}
+				var next string
+				next, content = cutLine(content)
+				line++
+				rest += "\n" + next
+			}
+			if quote == '"' {
+				value = unescapeDotenv(rest)
+			} else {
+				value = rest
+			}
+		} else {
+			// unquoted values end at a comment
+			if comment := strings.Index(raw, " #"); comment >= 0 {
+				raw = raw[:comment]
+			}
+			value = strings.TrimSpace(raw)
+		}
+
+		if idx, ok := index[key]; ok {
+			vars[idx].Value = value
+			continue
+		}
+		index[key] = len(vars)
+		vars = append(vars, dotenvVar{Key: key, Value: value})
+	}
+	return vars, nil
+}
+
+func cutLine(content string) (string, string) {
+	if idx := strings.Index(content, "\n"); idx >= 0 {
+		return content[:idx], content[idx+1:]
+	}
+	return content, ""
+}
+
+// closingQuote returns the index of the quote that ends the value, skipping escaped double quotes
+func closingQuote(value string, quote byte) int {
+	for i := 0; i < len(value); i++ {
+		if quote == '"' && value[i] == '\\' {
+			i++
+			continue
+		}
+		if value[i] == quote {
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeDotenv(value string) string {
+	var result strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			result.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n':
+			result.WriteByte('\n')
+		case 'r':
+			result.WriteByte('\r')
+		case 't':
+			result.WriteByte('\t')
+		case '"', '\\', '$', '\'':
+			result.WriteByte(value[i])
+		default:
+			result.WriteByte('\\')
+			result.WriteByte(value[i])
+		}
+	}
+	return result.String()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"github.com/cloud66-oss/cloud66"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Dotenv", func() {
+	It("should parse quotes, escapes and multi-line values", func() {
+		vars, err := parseDotenv(`# database
+export DATABASE_URL=postgres://db/app # the main one
+GREETING="hello \"world\"\n"
+LITERAL='no \n escape'
+EMPTY=
+KEY="-----BEGIN KEY-----
+abc
+-----END KEY-----"
+GREETING=again
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(vars).To(Equal([]dotenvVar{
+			{"DATABASE_URL", "postgres://db/app"},
+			{"GREETING", "again"},
+			{"LITERAL", `no \n escape`},
+			{"EMPTY", ""},
+			{"KEY", "-----BEGIN KEY-----\nabc\n-----END KEY-----"},
+		}))
+
+		_, err = parseDotenv("A=1\nB=\"open\n")
+		Expect(err).To(MatchError(ContainSubstring("line 2: missing closing quote")))
+		_, err = parseDotenv("A=1\nnot a variable\n")
+		Expect(err).To(MatchError(ContainSubstring("line 2")))
+	})
+
+	It("should plan the import against the stack", func() {
+		plan := planEnvVarImport(
+			[]dotenvVar{{"NEW", "1"}, {"SAME", "2"}, {"CHANGED", "3"}, {"STACK_BASE", "/tmp"}, {"PORT", "80"}},
+			[]cloud66.StackEnvVar{
+				{Key: "SAME", Value: "2"},
+				{Key: "CHANGED", Value: "old"},
+				{Key: "STACK_BASE", Value: "/var/deploy", Readonly: true},
+				{Key: "PORT", Value: float64(80)},
+			},
+		)
+		var actions []string
+		for _, item := range plan {
+			actions = append(actions, item.Key+":"+item.Action)
+		}
+		Expect(actions).To(Equal([]string{"CHANGED:change", "NEW:add", "PORT:unchanged", "SAME:unchanged", "STACK_BASE:readonly"}))
+	})
+})
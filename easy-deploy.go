@@ -71,6 +71,10 @@ func runEasyDeploy(c *cli.Context) {
 			return
 		}
 
+		if printStructured(list) {
+			return
+		}
+
 		for _, easyDeploy := range list {
 			fmt.Println(easyDeploy)
 		}
@@ -79,6 +83,9 @@ func runEasyDeploy(c *cli.Context) {
 
 func printEasyDeployList(w io.Writer, easyDeploys []cloud66.EasyDeploy) {
 	sort.Sort(easyDeploysByName(easyDeploys))
+	if printStructured(easyDeploys) {
+		return
+	}
 	for _, a := range easyDeploys {
 		listEasyDeploy(w, a)
 	}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66/cli"
+)
+
+const (
+	envVarAdded   = "added"
+	envVarRemoved = "removed"
+	envVarChanged = "changed"
+)
+
+// envVarDiff is an environment variable that is not the same on two stacks
+type envVarDiff struct {
+	Key string `json:"key"`
+	// Status is added when the variable is only on the stack, removed when it is only on the
+	// other stack and changed when the values are different
+	Status       string  `json:"status"`
+	Value        *string `json:"value,omitempty"`
+	AgainstValue *string `json:"against_value,omitempty"`
+}
+
+func runEnvVarsDiff(c *cli.Context) {
+	if c.String("against") == "" {
+		cli.ShowSubcommandHelp(c)
+		os.Exit(2)
+	}
+
+	// like diff, errors are an exit code of 2 so they can be told apart from differences
+	stack, err := stack(c)
+	if err != nil {
+		envVarsDiffFatal(err.Error())
+	}
+	if stack == nil {
+		envVarsDiffFatal("No stack specified. Either use --stack flag to cd to a stack directory")
+	}
+	against, err := stackByName(c.String("against"), c.String("against-environment"))
+	if err != nil {
+		envVarsDiffFatal(err.Error())
+	}
+
+	envVars, err := client.StackEnvVars(stack.Uid)
+	if err != nil {
+		envVarsDiffFatal(err.Error())
+	}
+	againstEnvVars, err := client.StackEnvVars(against.Uid)
+	if err != nil {
+		envVarsDiffFatal(err.Error())
+	}
+
+	filter := func(envVars []cloud66.StackEnvVar) map[string]string {
+		return envVarValues(envVars, c.Bool("include-readonly"), c.StringSlice("exclude"))
+	}
+	diffs := diffEnvVars(filter(envVars), filter(againstEnvVars))
+
+	if !c.Bool("show-values") {
+		for idx := range diffs {
+			diffs[idx].Value = maskEnvVar(diffs[idx].Value)
+			diffs[idx].AgainstValue = maskEnvVar(diffs[idx].AgainstValue)
+		}
+	}
+
+	if !printStructured(diffs) {
+		printEnvVarDiffs(diffs, *stack, *against, c.Bool("show-values"))
+	}
+	// like diff, differences are an exit code of 1
+	if len(diffs) > 0 {
+		os.Exit(1)
+	}
+}
+
+func runEnvVarsSync(c *cli.Context) {
+	if c.String("from") == "" || (len(c.StringSlice("key")) == 0 && !c.Bool("all")) {
+		cli.ShowSubcommandHelp(c)
+		os.Exit(2)
+	}
+
+	stack := mustStack(c)
+	from := mustOtherStack(c.String("from"), c.String("from-environment"))
+	if from.Uid == stack.Uid {
+		printFatal("The stacks to sync from and to are the same")
+	}
+
+	fromEnvVars, err := client.StackEnvVars(from.Uid)
+	must(err)
+	envVars, err := client.StackEnvVars(stack.Uid)
+	must(err)
+
+	// the readonly variables are set by Cloud 66 for each stack
+	var vars []dotenvVar
+	for key, value := range envVarValues(fromEnvVars, false, c.StringSlice("exclude")) {
+		if c.Bool("all") || envVarMatches(key, c.StringSlice("key")) {
+			vars = append(vars, dotenvVar{Key: key, Value: value})
+		}
+	}
+	if len(vars) == 0 {
+		printFatal("No variables of %s match the given keys", from.Name)
+	}
+
+	plan := planEnvVarImport(vars, envVars)
+	var changes []envVarPlan
+	for _, item := range plan {
+		if item.Action == envVarAdd || item.Action == envVarChange {
+			changes = append(changes, item)
+		}
+	}
+
+	if !printStructured(plan) {
+		printEnvVarPlan(plan)
+	}
+	if len(changes) == 0 || c.Bool("dry-run") {
+		return
+	}
+
+	if !c.Bool("y") {
+		mustConfirm(fmt.Sprintf("This copies %d environment variables from %s to %s. Each one is applied on its own and restarts the processes of %s. Proceed? [yes/N]", len(changes), from.Name, stack.Name, stack.Name), "yes")
+	}
+
+	applyEnvVarChanges(stack.Uid, changes)
+	fmt.Fprintf(os.Stderr, "%d environment variables copied from %s to %s\n", len(changes), from.Name, stack.Name)
+}
+
+// mustOtherStack finds the second stack of a command, by name and environment
+func mustOtherStack(name string, environment string) *cloud66.Stack {
+	stack, err := stackByName(name, environment)
+	if err != nil {
+		printFatal(err.Error())
+	}
+	return stack
+}
+
+// envVarsDiffFatal shows the error and exits with 2, as 1 is for differences
+func envVarsDiffFatal(message string, args ...interface{}) {
+	printError(message, args...)
+	os.Exit(2)
+}
+
+// diffEnvVars returns the variables that are not the same in values and against, by key
+func diffEnvVars(values map[string]string, against map[string]string) []envVarDiff {
+	var diffs []envVarDiff
+	for key, value := range values {
+		value := value
+		againstValue, ok := against[key]
+		switch {
+		case !ok:
+			diffs = append(diffs, envVarDiff{Key: key, Status: envVarAdded, Value: &value})
+		case value != againstValue:
+			diffs = append(diffs, envVarDiff{Key: key, Status: envVarChanged, Value: &value, AgainstValue: &againstValue})
+		}
+	}
+	for key, againstValue := range against {
+		againstValue := againstValue
+		if _, ok := values[key]; !ok {
+			diffs = append(diffs, envVarDiff{Key: key, Status: envVarRemoved, AgainstValue: &againstValue})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Key < diffs[j].Key })
+	return diffs
+}
+
+func maskEnvVar(value *string) *string {
+	if value == nil || *value == "" {
+		return value
+	}
+	masked := "********"
+	return &masked
+}
+
+func printEnvVarDiffs(diffs []envVarDiff, stack cloud66.Stack, against cloud66.Stack, showValues bool) {
+	if len(diffs) == 0 {
+		fmt.Printf("The environment variables of %s and %s are the same\n", stack.Name, against.Name)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+	defer w.Flush()
+
+	listRec(w, "", "KEY", fmt.Sprintf("%s (%s)", stack.Name, stack.Environment), fmt.Sprintf("%s (%s)", against.Name, against.Environment))
+	for _, diff := range diffs {
+		sign := "~"
+		switch diff.Status {
+		case envVarAdded:
+			sign = "+"
+		case envVarRemoved:
+			sign = "-"
+		}
+		listRec(w, sign, diff.Key, diffValue(diff.Value, showValues), diffValue(diff.AgainstValue, showValues))
+	}
+}
+
+// diffValue quotes the values that are shown so they fit on a line
+func diffValue(value *string, showValues bool) string {
+	switch {
+	case value == nil:
+		return "(not set)"
+	case *value == "":
+		return "(empty)"
+	case showValues:
+		return fmt.Sprintf("%q", *value)
+	default:
+		return *value
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/cloud66-oss/cloud66"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Env vars diff", func() {
+	It("should diff the variables of two stacks", func() {
+		diffs := diffEnvVars(
+			map[string]string{"ONLY_HERE": "1", "SAME": "2", "CHANGED": "new"},
+			map[string]string{"ONLY_THERE": "3", "SAME": "2", "CHANGED": "old"},
+		)
+		var statuses []string
+		for _, diff := range diffs {
+			statuses = append(statuses, diff.Key+":"+diff.Status)
+		}
+		Expect(statuses).To(Equal([]string{"CHANGED:changed", "ONLY_HERE:added", "ONLY_THERE:removed"}))
+		Expect(*diffs[0].Value).To(Equal("new"))
+		Expect(*diffs[0].AgainstValue).To(Equal("old"))
+		Expect(diffs[2].Value).To(BeNil())
+		Expect(*maskEnvVar(diffs[0].Value)).To(Equal("********"))
+
+		values := envVarValues([]cloud66.StackEnvVar{
+			{Key: "AWS_KEY", Value: "a"},
+			{Key: "STACK_BASE", Value: "/var/deploy", Readonly: true},
+			{Key: "PORT", Value: float64(80)},
+		}, false, []string{"AWS_*"})
+		Expect(values).To(Equal(map[string]string{"PORT": "80"}))
+	})
+})
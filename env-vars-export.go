@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66/cli"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	shellKeyPattern   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	dotenvPlainValue  = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,-]*$`)
+	secretNamePattern = regexp.MustCompile(`[^a-z0-9.-]+`)
+)
+
+// envVarFormats are the formats of cx env-vars export
+var envVarFormats = []string{"dotenv", "json", "shell", "k8s-secret"}
+
+func runEnvVarsExport(c *cli.Context) {
+	format := c.String("format")
+	if _, err := formatEnvVars(nil, format, ""); err != nil {
+		printFatal(err.Error())
+	}
+	stack := mustStack(c)
+
+	envVars, err := client.StackEnvVars(stack.Uid)
+	must(err)
+
+	var vars []dotenvVar
+	for key, value := range envVarValues(envVars, c.Bool("include-readonly"), c.StringSlice("exclude")) {
+		vars = append(vars, dotenvVar{Key: key, Value: value})
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Key < vars[j].Key })
+
+	name := c.String("name")
+	if name == "" {
+		name = secretName(stack.Name + "-" + stack.Environment)
+	}
+	out, err := formatEnvVars(vars, format, name)
+	if err != nil {
+		printFatal(err.Error())
+	}
+
+	if c.String("file") == "" {
+		os.Stdout.Write(out)
+		return
+	}
+	// the values are often secrets
+	if err := ioutil.WriteFile(c.String("file"), out, 0600); err != nil {
+		printFatal(err.Error())
+	}
+	fmt.Fprintf(os.Stderr, "%d environment variables written to %s\n", len(vars), c.String("file"))
+}
+
+// envVarValues returns the values of the environment variables by key, leaving out the readonly
+// ones unless includeReadonly and the ones matching the exclude patterns
+func envVarValues(envVars []cloud66.StackEnvVar, includeReadonly bool, exclude []string) map[string]string {
+	values := map[string]string{}
+	for _, envVar := range envVars {
+		if envVar.Key == "" || (envVar.Readonly && !includeReadonly) || envVarMatches(envVar.Key, exclude) {
+			continue
+		}
+		values[envVar.Key] = envVarString(envVar.Value)
+	}
+	return values
+}
+
+// envVarMatches returns true if the key matches one of the patterns, like DB_* or *_SECRET
+func envVarMatches(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, key); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// formatEnvVars writes the variables in one of the envVarFormats. name is the name of the
+// Kubernetes secret
+func formatEnvVars(vars []dotenvVar, format string, name string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "dotenv":
+		for _, v := range vars {
+			fmt.Fprintf(&buf, "%s=%s\n", v.Key, dotenvQuote(v.Value))
+		}
+	case "json":
+		values := map[string]string{}
+		for _, v := range vars {
+			values[v.Key] = v.Value
+		}
+		out, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(out)
+		buf.WriteString("\n")
+	case "shell":
+		for _, v := range vars {
+			if !shellKeyPattern.MatchString(v.Key) {
+				printWarning("%s is not a valid shell variable name and is skipped", v.Key)
+				continue
+			}
+			fmt.Fprintf(&buf, "export %s=%s\n", v.Key, shellQuote(v.Value))
+		}
+	case "k8s-secret":
+		data := yaml.MapSlice{}
+		for _, v := range vars {
+			data = append(data, yaml.MapItem{Key: v.Key, Value: base64.StdEncoding.EncodeToString([]byte(v.Value))})
+		}
+		secret := yaml.MapSlice{
+			{Key: "apiVersion", Value: "v1"},
+			{Key: "kind", Value: "Secret"},
+			{Key: "metadata", Value: yaml.MapSlice{{Key: "name", Value: name}}},
+			{Key: "type", Value: "Opaque"},
+			{Key: "data", Value: data},
+		}
+		out, err := yaml.Marshal(secret)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(out)
+	default:
+		return nil, fmt.Errorf("unsupported format %s. Use one of %s", format, strings.Join(envVarFormats, ", "))
+	}
+	return buf.Bytes(), nil
+}
+
+// dotenvQuote puts the value in double quotes, with escapes, unless it is plain
+func dotenvQuote(value string) string {
+	if dotenvPlainValue.MatchString(value) {
+		return value
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "$", `\$`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// shellQuote puts the value in single quotes so nothing in it is expanded by the shell
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+// secretName turns a name into a valid Kubernetes resource name
+func secretName(name string) string {
+	name = secretNamePattern.ReplaceAllString(strings.ToLower(name), "-")
+	return strings.Trim(name, "-.")
+}
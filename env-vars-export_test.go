@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Env vars export", func() {
+	It("should export values that read back the same", func() {
+		vars := []dotenvVar{
+			{"PLAIN", "postgres://db:5432/app"},
+			{"QUOTED", "it's \"quoted\" $HOME\\path"},
+			{"MULTI", "line one\nline two"},
+			{"EMPTY", ""},
+		}
+
+		out, err := formatEnvVars(vars, "dotenv", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(HavePrefix("PLAIN=postgres://db:5432/app\n"))
+		parsed, err := parseDotenv(string(out))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed).To(Equal(vars))
+
+		out, err = formatEnvVars(vars, "json", "")
+		Expect(err).NotTo(HaveOccurred())
+		var values map[string]string
+		Expect(json.Unmarshal(out, &values)).To(Succeed())
+		Expect(values).To(HaveKeyWithValue("QUOTED", vars[1].Value))
+
+		out, err = formatEnvVars(vars[1:2], "shell", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal(`export QUOTED='it'\''s "quoted" $HOME\path'` + "\n"))
+
+		out, err = formatEnvVars(vars[:1], "k8s-secret", secretName("My App-production"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(ContainSubstring("name: my-app-production"))
+		Expect(string(out)).To(ContainSubstring("PLAIN: cG9zdGdyZXM6Ly9kYjo1NDMyL2FwcA=="))
+
+		_, err = formatEnvVars(vars, "xml", "")
+		Expect(err).To(HaveOccurred())
+	})
+})
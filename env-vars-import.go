@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66/cli"
+)
+
+const (
+	envVarAdd       = "add"
+	envVarChange    = "change"
+	envVarUnchanged = "unchanged"
+	envVarReadonly  = "readonly"
+)
+
+// envVarPlan is what an import does to an environment variable
+type envVarPlan struct {
+	Key string `json:"key"`
+	// Action is add, change, unchanged or readonly. Readonly variables are skipped
+	Action string `json:"action"`
+	Value  string `json:"-"`
+}
+
+func runEnvVarsImport(c *cli.Context) {
+	if len(c.Args()) != 1 {
+		cli.ShowSubcommandHelp(c)
+		os.Exit(2)
+	}
+
+	content, err := ioutil.ReadFile(c.Args()[0])
+	must(err)
+	vars, err := parseDotenv(string(content))
+	if err != nil {
+		printFatal("Invalid file %s: %s", c.Args()[0], err)
+	}
+
+	stack := mustStack(c)
+	envVars, err := client.StackEnvVars(stack.Uid)
+	must(err)
+
+	plan := planEnvVarImport(vars, envVars)
+	var changes []envVarPlan
+	for _, item := range plan {
+		if item.Action == envVarAdd || item.Action == envVarChange {
+			changes = append(changes, item)
+		}
+	}
+
+	if !printStructured(plan) {
+		printEnvVarPlan(plan)
+	}
+	if len(changes) == 0 || c.Bool("dry-run") {
+		return
+	}
+
+	if !c.Bool("y") {
+		mustConfirm(fmt.Sprintf("This sets %d environment variables on %s. Each one is applied on its own and restarts the processes of %s. Proceed? [yes/N]", len(changes), stack.Name, stack.Name), "yes")
+	}
+
+	applyEnvVarChanges(stack.Uid, changes)
+	fmt.Fprintf(os.Stderr, "%d environment variables imported to %s\n", len(changes), stack.Name)
+}
+
+// planEnvVarImport compares the variables of a file to the ones of the stack
+func planEnvVarImport(vars []dotenvVar, envVars []cloud66.StackEnvVar) []envVarPlan {
+	existing := map[string]cloud66.StackEnvVar{}
+	for _, envVar := range envVars {
+		existing[envVar.Key] = envVar
+	}
+
+	var plan []envVarPlan
+	for _, v := range vars {
+		item := envVarPlan{Key: v.Key, Value: v.Value, Action: envVarAdd}
+		if envVar, ok := existing[v.Key]; ok {
+			switch {
+			case envVar.Readonly:
+				item.Action = envVarReadonly
+			case envVarString(envVar.Value) == v.Value:
+				item.Action = envVarUnchanged
+			default:
+				item.Action = envVarChange
+			}
+		}
+		plan = append(plan, item)
+	}
+	sort.SliceStable(plan, func(i, j int) bool { return plan[i].Key < plan[j].Key })
+	return plan
+}
+
+// printEnvVarPlan shows the changes without their values, which are often secrets
+func printEnvVarPlan(plan []envVarPlan) {
+	counts := map[string]int{}
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+	for _, item := range plan {
+		counts[item.Action]++
+		switch item.Action {
+		case envVarAdd:
+			listRec(w, "+", item.Key, "new")
+		case envVarChange:
+			listRec(w, "~", item.Key, "changed")
+		case envVarReadonly:
+			listRec(w, "!", item.Key, "readonly, skipped")
+		}
+	}
+	w.Flush()
+
+	fmt.Printf("%d to add, %d to change, %d unchanged, %d readonly skipped\n",
+		counts[envVarAdd], counts[envVarChange], counts[envVarUnchanged], counts[envVarReadonly])
+}
+
+// envVarString returns the value of an environment variable of the stack as it is in a file
+func envVarString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(buf)
+	}
+}
+
+// applyEnvVarChanges sets and applies the changed variables one by one, like cx env-vars set. The
+// API applies each variable as it is set and has no way to set many of them and apply them once.
+// The progress goes to stderr so only the plan is on stdout with --output. With --no-wait the ids
+// of the actions are shown instead of waiting for each of them
+func applyEnvVarChanges(stackUid string, changes []envVarPlan) {
+	for _, change := range changes {
+		fmt.Fprintf(os.Stderr, "Setting %s...\n", change.Key)
+		asyncId, err := startEnvVarSet(stackUid, change.Key, change.Value, change.Action == envVarChange)
+		if err != nil {
+			printFatal(err.Error())
+		}
+		genericRes, err := endEnvVarSet(*asyncId, stackUid)
+		if err == errActionNotWaited {
+			fmt.Fprintf(os.Stderr, "Action %d started\n", *asyncId)
+			if !structuredOutput() {
+				fmt.Println(*asyncId)
+			}
+			continue
+		}
+		if err != nil {
+			printFatal(err.Error())
+		}
+		if !genericRes.Status {
+			printFatal("Setting %s failed: %s", change.Key, genericRes.Message)
+		}
+	}
+}
@@ -51,6 +51,9 @@ func runEnvVarsSet(c *cli.Context) {
 		printFatal(err.Error())
 	}
 	genericRes, err := endEnvVarSet(*asyncId, stack.Uid)
+	if actionNotWaited(err, *asyncId) {
+		return
+	}
 	if err != nil {
 		printFatal(err.Error())
 	}
@@ -76,5 +79,5 @@ func startEnvVarSet(stackUid string, key string, value string, existing bool) (*
 }
 
 func endEnvVarSet(asyncId int, stackUid string) (*cloud66.GenericResponse, error) {
-	return client.WaitStackAsyncAction(asyncId, stackUid, 3*time.Second, 20*time.Minute, true)
+	return waitStackAction(asyncId, stackUid, 3*time.Second, 20*time.Minute, true)
 }
@@ -76,6 +76,171 @@ being sent to your stack servers, and your processes being restarted immediately
 Examples:
 $ cx env-vars set -s mystack FIRST_VAR=123
 $ cx env-vars set -s mystack SECOND_ONE='this value has a space in it'
+`,
+		},
+		cli.Command{
+			Name:   "import",
+			Usage:  "sets the environment variables of a .env file on a stack",
+			Action: runEnvVarsImport,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "only show what would change",
+				},
+				cli.BoolFlag{
+					Name:  "y",
+					Usage: "answer yes to confirmations",
+				},
+			},
+			Description: `This sets the environment variables of a .env file on a stack. The API applies each variable as it
+is set, so they can't be applied in one go: each one is set and applied on its own like with 'cx env-vars set'
+and your processes are restarted for each of them.
+
+Values can be unquoted, in single quotes (taken as they are) or in double quotes (with \n, \t, \" and \\ escapes).
+Quoted values can span many lines. Lines starting with # are comments and 'export' in front of a key is ignored.
+
+The variables that would be added or changed are shown first, without their values. The ones that are the same
+on the stack are left as they are and the readonly ones are skipped.
+
+Examples:
+$ cx env-vars import -s mystack .env
+$ cx env-vars import -s mystack --dry-run .env.production
+$ cx env-vars import -s mystack -y .env
+`,
+		},
+		cli.Command{
+			Name:   "export",
+			Usage:  "writes the environment variables of a stack to a file",
+			Action: runEnvVarsExport,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "format",
+					Usage: "dotenv, json, shell or k8s-secret",
+					Value: "dotenv",
+				},
+				cli.StringFlag{
+					Name:  "file,f",
+					Usage: "file to write to instead of stdout",
+				},
+				cli.BoolFlag{
+					Name:  "include-readonly",
+					Usage: "also export the readonly variables set by Cloud 66",
+				},
+				cli.StringSliceFlag{
+					Name:  "exclude",
+					Usage: "leave out the variables matching this pattern (ie. 'AWS_*'). Repeatable",
+					Value: &cli.StringSlice{},
+				},
+				cli.StringFlag{
+					Name:  "name",
+					Usage: "name of the secret with --format k8s-secret. The stack name and environment by default",
+				},
+			},
+			Description: `This writes the environment variables of a stack to stdout or a file, for example to run the
+application locally with the same settings. The readonly variables set by Cloud 66 are left out unless
+--include-readonly is given.
+
+The formats are:
+  dotenv      KEY=value lines that can be used with 'cx env-vars import'
+  json        an object of the keys and their values
+  shell       export KEY='value' lines that can be sourced
+  k8s-secret  a Kubernetes secret with the variables
+
+Files are written so only their owner can read them.
+
+Examples:
+$ cx env-vars export -s mystack -e staging -f .env
+$ cx env-vars export -s mystack --format json --exclude 'AWS_*' --exclude '*_SECRET'
+$ eval "$(cx env-vars export -s mystack --format shell)"
+$ cx env-vars export -s mystack --format k8s-secret | kubectl apply -f -
+`,
+		},
+		cli.Command{
+			Name:   "diff",
+			Usage:  "compares the environment variables of two stacks",
+			Action: runEnvVarsDiff,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "against",
+					Usage: "full or partial name of the stack to compare to",
+				},
+				cli.StringFlag{
+					Name:  "against-environment",
+					Usage: "full or partial environment name of the stack to compare to",
+				},
+				cli.BoolFlag{
+					Name:  "show-values",
+					Usage: "show the values instead of masking them",
+				},
+				cli.BoolFlag{
+					Name:  "include-readonly",
+					Usage: "also compare the readonly variables set by Cloud 66",
+				},
+				cli.StringSliceFlag{
+					Name:  "exclude",
+					Usage: "leave out the variables matching this pattern (ie. 'AWS_*'). Repeatable",
+					Value: &cli.StringSlice{},
+				},
+			},
+			Description: `This shows the environment variables that are only on the stack (+), only on the stack given with
+--against (-) or different on both (~). The values are masked unless --show-values is given.
+
+The readonly variables set by Cloud 66, like the addresses of the servers, are different on every stack and
+are left out unless --include-readonly is given. Like diff, it exits with 1 when there are differences and
+with 2 on errors.
+
+Examples:
+$ cx env-vars diff -s app-staging --against app-production
+$ cx env-vars diff -s app -e staging --against app --against-environment production --show-values
+$ cx --output json env-vars diff -s app-staging --against app-production --exclude 'AWS_*'
+`,
+		},
+		cli.Command{
+			Name:   "sync",
+			Usage:  "copies environment variables from another stack",
+			Action: runEnvVarsSync,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "from",
+					Usage: "full or partial name of the stack to copy from",
+				},
+				cli.StringFlag{
+					Name:  "from-environment",
+					Usage: "full or partial environment name of the stack to copy from",
+				},
+				cli.StringSliceFlag{
+					Name:  "key",
+					Usage: "copy the variables matching this key or pattern (ie. 'SMTP_*'). Repeatable",
+					Value: &cli.StringSlice{},
+				},
+				cli.BoolFlag{
+					Name:  "all",
+					Usage: "copy all the variables that are not readonly",
+				},
+				cli.StringSliceFlag{
+					Name:  "exclude",
+					Usage: "leave out the variables matching this pattern. Repeatable",
+					Value: &cli.StringSlice{},
+				},
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "only show what would change",
+				},
+				cli.BoolFlag{
+					Name:  "y",
+					Usage: "answer yes to confirmations",
+				},
+			},
+			Description: `This copies the given environment variables from the stack given with --from to the stack. The
+variables that would be added or changed are shown first, without their values. The readonly variables of
+both stacks are skipped.
+
+Each variable is set and applied on its own like with 'cx env-vars set'.
+
+Examples:
+$ cx env-vars sync -s app-production --from app-staging --key SMTP_HOST --key SMTP_PORT
+$ cx env-vars sync -s app-production --from app-staging --key 'FEATURE_*' --dry-run
+$ cx env-vars sync -s app -e production --from app --from-environment staging --all --exclude 'DATABASE_*'
 `,
 		},
 	}
@@ -113,6 +278,9 @@ func runEnvVars(c *cli.Context) {
 
 func printEnvVarsList(w io.Writer, envVars []cloud66.StackEnvVar, showHistory bool) {
 	sort.Sort(envVarsByName(envVars))
+	if printStructured(envVars) {
+		return
+	}
 	for _, a := range envVars {
 		if a.Key != "" {
 			listEnvVar(w, a, showHistory)
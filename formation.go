@@ -628,6 +628,9 @@ func listFormation(w io.Writer, a cloud66.Formation) {
 
 func printFormationList(w io.Writer, formations []cloud66.Formation) {
 	sort.Sort(formationByName(formations))
+	if printStructured(formations) {
+		return
+	}
 
 	listRec(w,
 		"UID",
@@ -782,6 +785,9 @@ func runAddStencil(c *cli.Context) {
 func printStencils(w io.Writer, formation cloud66.Formation, output string) {
 	stencils := formation.Stencils
 	sort.Sort(stencilBySequence(stencils))
+	if printStructured(stencils) {
+		return
+	}
 
 	if output == "standard" {
 		listRec(w,
@@ -126,6 +126,10 @@ func runListGateways(c *cli.Context) {
 		result = append(result, g)
 	}
 
+	if printStructured(result) {
+		return
+	}
+
 	if result != nil {
 		w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
 		defer w.Flush()
@@ -140,12 +144,7 @@ func runListGateways(c *cli.Context) {
 			}
 
 			for _, g := range result {
-				state := "N/A"
-				if (len(g.Content) > 0) && (strings.Compare(g.Content, "N/A") != 0) {
-					state = "open"
-				} else {
-					state = "close"
-				}
+				state := gatewayState(g)
 
 				ttl_string := "N/A"
 				t, err := time.Parse("2006-01-02T15:04:05Z", g.Ttl)
@@ -273,17 +272,9 @@ func runOpenGateway(c *cli.Context) {
 			os.Exit(2)
 		}
 
-		keyfilePath := expandPath(flagKeyFile)
-		keyContent, err := ioutil.ReadFile(keyfilePath)
+		err := openGateway(accountId, gatewayId, flagKeyFile, ttlValue)
 		if err != nil {
-			printFatal("Can not read from %s : "+err.Error(), keyfilePath)
-			os.Exit(2)
-		}
-
-		err = client.UpdateGateway(accountId, gatewayId, string(keyContent), ttlValue)
-
-		if err != nil {
-			printFatal("Error opening gateway : " + err.Error())
+			printFatal(err.Error())
 			os.Exit(2)
 		}
 		fmt.Println("Gateway opened successfully!")
@@ -361,11 +352,7 @@ func findGatwayInfo(c *cli.Context, gatewayName string) (int, int, string) {
 			if strings.Compare(g.Name, gatewayName) == 0 {
 				resultGatewayId = g.Id
 				resultAccountId = org.Id
-				if (len(g.Content) > 0) && (strings.Compare(g.Content, "N/A") != 0) {
-					resultState = "open"
-				} else {
-					resultState = "close"
-				}
+				resultState = gatewayState(g)
 				break
 			}
 		}
@@ -378,3 +365,25 @@ func findGatwayInfo(c *cli.Context, gatewayName string) (int, int, string) {
 
 	return resultAccountId, resultGatewayId, resultState
 }
+
+func gatewayState(gateway cloud66.Gateway) string {
+	if (len(gateway.Content) > 0) && (strings.Compare(gateway.Content, "N/A") != 0) {
+		return "open"
+	}
+	return "close"
+}
+
+// openGateway makes the gateway available to Cloud 66 with the given key for ttl seconds
+func openGateway(accountId int, gatewayId int, keyFile string, ttl int) error {
+	keyfilePath := expandPath(keyFile)
+	keyContent, err := ioutil.ReadFile(keyfilePath)
+	if err != nil {
+		return fmt.Errorf("Can not read from %s : %s", keyfilePath, err.Error())
+	}
+
+	err = client.UpdateGateway(accountId, gatewayId, string(keyContent), ttl)
+	if err != nil {
+		return fmt.Errorf("Error opening gateway : %s", err.Error())
+	}
+	return nil
+}
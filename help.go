@@ -16,6 +16,14 @@ CXDEBUG
   response. This will most likely include your secret API key in
   the Authorization header field, so be careful with the output.
 
+CXGATEWAYKEY
+	Path to the key of the deploy gateway (bastion server) used to reach servers
+  behind a gateway. The --gateway-key flag takes precedence over it.
+
+CXOUTPUT
+	Sets the default output format of list and show commands. Valid values
+  are table, json and yaml. The --output flag takes precedence over it.
+
 CXTOKEN
 	Anything set to this will be passed as X-CxToken HTTP header to the server.
 	Used for development purposes.
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// interruptStop is something onInterrupt stops
+type interruptStop struct {
+	id   int
+	stop func()
+}
+
+// Ctrl-C and SIGTERM are handled in one place for all the commands: the first one closes
+// interruptChan and calls the functions given to onInterrupt, so the commands stop what they
+// are doing and return normally with their deferred cleanups. A second one stops cx right away
+var (
+	interruptOnce  sync.Once
+	interruptLock  sync.Mutex
+	interruptChan  = make(chan struct{})
+	interruptStops []interruptStop
+	interruptId    int
+)
+
+func watchInterrupts() {
+	interruptOnce.Do(func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			signal.Stop(signals)
+
+			interruptLock.Lock()
+			close(interruptChan)
+			stops := interruptStops
+			interruptStops = nil
+			interruptLock.Unlock()
+
+			// the last one started is stopped first, like deferred calls
+			for idx := len(stops) - 1; idx >= 0; idx-- {
+				stops[idx].stop()
+			}
+		}()
+	})
+}
+
+// interrupted returns a channel that is closed on the first Ctrl-C or SIGTERM
+func interrupted() <-chan struct{} {
+	watchInterrupts()
+	return interruptChan
+}
+
+// isInterrupted returns true once Ctrl-C or SIGTERM has been received
+func isInterrupted() bool {
+	select {
+	case <-interruptChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// onInterrupt calls stop on the first Ctrl-C or SIGTERM, or right away if it has been received
+// already. The returned function removes it once there is nothing left to stop
+func onInterrupt(stop func()) func() {
+	watchInterrupts()
+
+	interruptLock.Lock()
+	if isInterrupted() {
+		interruptLock.Unlock()
+		stop()
+		return func() {}
+	}
+	interruptId++
+	id := interruptId
+	interruptStops = append(interruptStops, interruptStop{id: id, stop: stop})
+	interruptLock.Unlock()
+
+	return func() {
+		interruptLock.Lock()
+		defer interruptLock.Unlock()
+		for idx, item := range interruptStops {
+			if item.id == id {
+				interruptStops = append(interruptStops[:idx], interruptStops[idx+1:]...)
+				return
+			}
+		}
+	}
+}
@@ -91,7 +91,9 @@ func runJobs(c *cli.Context) {
 		if !server.HasRole("docker") && !server.HasRole("kubes") {
 			printFatal("Server '" + flagServer + "' can not host containers")
 		}
-		fmt.Printf("Server: %s\n", server.Name)
+		if !structuredOutput() {
+			fmt.Printf("Server: %s\n", server.Name)
+		}
 		serverUid = &server.Uid
 	}
 
@@ -118,6 +120,10 @@ func runJobs(c *cli.Context) {
 }
 
 func printJobsList(w io.Writer, jobs []cloud66.Job, flagServer string) {
+	if printStructured(jobs) {
+		return
+	}
+
 	listRec(w,
 		"JOB NAME",
 		"TYPE",
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+)
+
+// serverLease is a firewall lease to a server that is renewed in the background for as
+// long as the session that needs it is running
+type serverLease struct {
+	server     cloud66.Server
+	serverId   *int
+	timeToOpen int
+	port       int
+
+	lock   sync.Mutex
+	fromIp string
+	rules  map[int]bool
+	stop   chan struct{}
+	done   chan struct{}
+	closed sync.Once
+}
+
+// openLeases are the leases of this session, so they can be revoked on Ctrl-C
+var (
+	openLeases     = map[*serverLease]bool{}
+	openLeasesLock sync.Mutex
+	leaseInterrupt sync.Once
+)
+
+// openServerLease opens the firewall of the server to this machine for timeToOpen minutes
+// and keeps renewing it until the lease is closed
+func openServerLease(server cloud66.Server, timeToOpen int) (*serverLease, error) {
+	lease := &serverLease{
+		server:     server,
+		timeToOpen: timeToOpen,
+		port:       22,
+		rules:      map[int]bool{},
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	lease.serverId = serverFirewallId(server)
+
+	// the rules that are already there belong to someone else
+	existing := map[int]bool{}
+	if rules, err := listFirewallRules(server.StackUid); err == nil {
+		for _, rule := range rules {
+			existing[rule.Id] = true
+		}
+	} else if debugMode {
+		printError("Unable to list the firewall rules: %s", err)
+	}
+
+	if err := lease.renew(existing); err != nil {
+		return nil, err
+	}
+
+	openLeasesLock.Lock()
+	openLeases[lease] = true
+	openLeasesLock.Unlock()
+	// the sessions using the leases are stopped first, as they are started after them
+	leaseInterrupt.Do(func() { onInterrupt(closeServerLeases) })
+
+	go lease.keepOpen(existing)
+	return lease, nil
+}
+
+// mustOpenServerLease opens a lease and exits if it fails
+func mustOpenServerLease(server cloud66.Server, timeToOpen int) *serverLease {
+	lease, err := openServerLease(server, timeToOpen)
+	must(err)
+	return lease
+}
+
+func (l *serverLease) renew(existing map[int]bool) error {
+	genericRes, err := client.LeaseSync(l.server.StackUid, nil, &l.timeToOpen, &l.port, &l.server.Uid)
+	if err != nil {
+		return err
+	}
+	if genericRes.Status != true {
+		return fmt.Errorf("Unable to open server lease")
+	}
+
+	rules, err := listFirewallRules(l.server.StackUid)
+	if err != nil {
+		// the lease is open, it just can't be revoked early
+		sshDebugf("Unable to list the firewall rules: %s", err)
+		return nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var claimed []firewallRule
+	for _, rule := range rules {
+		if !existing[rule.Id] && !l.rules[rule.Id] && l.owns(rule) {
+			claimed = append(claimed, rule)
+		}
+	}
+	// the source IP is only known once the first lease is open. If the server was opened
+	// to more than one address at the same time, the lease can't be told apart
+	if l.fromIp == "" && len(claimed) > 0 {
+		for _, rule := range claimed[1:] {
+			if rule.FromIp != claimed[0].FromIp {
+				sshDebugf("Unable to tell the lease to %s apart from the others", l.server.Address)
+				return nil
+			}
+		}
+		l.fromIp = claimed[0].FromIp
+	}
+	for _, rule := range claimed {
+		l.rules[rule.Id] = true
+	}
+	return nil
+}
+
+// owns returns true if the rule is a lease to the server and port of this lease, from its source IP
+func (l *serverLease) owns(rule firewallRule) bool {
+	if !rule.IsLease() || rule.Port != l.port {
+		return false
+	}
+	if l.fromIp != "" && rule.FromIp != l.fromIp {
+		return false
+	}
+	switch {
+	case rule.ToServerId != nil:
+		return l.serverId != nil && *rule.ToServerId == *l.serverId
+	case rule.ToIp != "":
+		return rule.ToIp == l.server.Address || rule.ToIp == l.server.ExtIpV4
+	}
+	return false
+}
+
+// serverFirewallId returns the id of the server used by the firewall rules, or nil if it can't be found.
+// It is read from the server endpoint of GetServer, as the Server of the SDK doesn't have it
+func serverFirewallId(server cloud66.Server) *int {
+	var result struct {
+		Id *int `json:"id"`
+	}
+	if err := client.Get(&result, "/stacks/"+server.StackUid+"/servers/"+server.Uid+".json", nil, nil); err != nil {
+		sshDebugf("Unable to find the id of %s: %s", server.Address, err)
+		return nil
+	}
+	return result.Id
+}
+
+// keepOpen renews the lease half way through its time to open
+func (l *serverLease) keepOpen(existing map[int]bool) {
+	defer close(l.done)
+
+	interval := time.Duration(l.timeToOpen) * time.Minute / 2
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			sshDebugf("Renewing the lease to %s", l.server.Address)
+			if err := l.renew(existing); err != nil {
+				printError("Unable to renew the lease to %s: %s", l.server.Address, err)
+			}
+		}
+	}
+}
+
+// Close stops renewing the lease and closes the firewall again. It returns once the lease is
+// closed, also when it is being closed by another goroutine
+func (l *serverLease) Close() {
+	l.closed.Do(l.close)
+}
+
+func (l *serverLease) close() {
+	openLeasesLock.Lock()
+	delete(openLeases, l)
+	openLeasesLock.Unlock()
+
+	close(l.stop)
+	<-l.done
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for id := range l.rules {
+		sshDebugf("Revoking lease %d to %s", id, l.server.Address)
+		if err := revokeFirewallRule(l.server.StackUid, id); err != nil {
+			printError("Unable to revoke the lease to %s: %s", l.server.Address, err)
+		}
+	}
+	l.rules = map[int]bool{}
+}
+
+// closeServerLeases closes all the open leases of this session
+func closeServerLeases() {
+	openLeasesLock.Lock()
+	leases := make([]*serverLease, 0, len(openLeases))
+	for lease := range openLeases {
+		leases = append(leases, lease)
+	}
+	openLeasesLock.Unlock()
+
+	for _, lease := range leases {
+		lease.Close()
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/cloud66-oss/cloud66"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server leases", func() {
+	It("should only own the leases to its server from its source IP", func() {
+		serverId, otherId := 521, 522
+		lease := &serverLease{
+			server:   cloud66.Server{Address: "10.0.0.1", ExtIpV4: "52.65.34.98"},
+			serverId: &serverId,
+			port:     22,
+		}
+
+		Expect(lease.owns(firewallRule{FromIp: "1.2.3.4", ToServerId: &serverId, Port: 22, Ttl: 20})).To(BeTrue())
+		Expect(lease.owns(firewallRule{FromIp: "1.2.3.4", ToIp: "52.65.34.98", Port: 22, Ttl: 20})).To(BeTrue())
+		Expect(lease.owns(firewallRule{FromIp: "1.2.3.4", ToServerId: &otherId, Port: 22, Ttl: 20})).To(BeFalse())
+		Expect(lease.owns(firewallRule{FromIp: "1.2.3.4", Port: 22, Ttl: 20})).To(BeFalse())
+		Expect(lease.owns(firewallRule{FromIp: "1.2.3.4", ToServerId: &serverId, Port: 3306, Ttl: 20})).To(BeFalse())
+		Expect(lease.owns(firewallRule{FromIp: "1.2.3.4", ToServerId: &serverId, Port: 22})).To(BeFalse())
+
+		lease.fromIp = "1.2.3.4"
+		Expect(lease.owns(firewallRule{FromIp: "1.2.3.4", ToServerId: &serverId, Port: 22, Ttl: 20})).To(BeTrue())
+		Expect(lease.owns(firewallRule{FromIp: "5.6.7.8", ToServerId: &serverId, Port: 22, Ttl: 20})).To(BeFalse())
+	})
+})
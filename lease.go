@@ -2,14 +2,19 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
 
+	"github.com/cloud66-oss/cloud66"
 	"github.com/cloud66/cli"
 )
 
 var cmdLease = &Command{
 	Run:   runLease,
 	Name:  "lease",
-	Build: buildBasicCommand,
+	Build: buildLease,
 	Flags: []cli.Flag{
 		cli.StringFlag{
 			Name:  "from,f",
@@ -26,6 +31,15 @@ var cmdLease = &Command{
 			Usage: "Port to open",
 			Value: 22,
 		},
+		// the stack flags are only added to the subcommands
+		cli.StringFlag{
+			Name:  "stack,s",
+			Usage: "full or partial stack name. This can be omitted if the current directory is a stack directory",
+		},
+		cli.StringFlag{
+			Name:  "environment,e",
+			Usage: "full or partial environment name",
+		},
 	},
 	NeedsStack: true,
 	NeedsOrg:   false,
@@ -37,14 +51,115 @@ By default the firewall is closed after 20 minutes. The maximum time to open is
 If no 'from IP' is specified, the caller's IP address (your IP address) is used.
 If no 'port' is used, the default is 22 (SSH).
 
+Commands that connect to servers (ssh, run, tunnel, tail, ...) open their own lease, renew it
+for as long as they run and close it when they exit.
+Use 'cx lease list' to see the open leases of a stack and 'cx lease revoke' to close them.
+
 Examples:
 $ cx lease -s mystack
 $ cx lease -s mystack -t 120 -p 3306
 $ cx lease -s mystack -p 3306 -f 52.65.34.98
+$ cx lease list -s mystack
+$ cx lease revoke -s mystack 1234
 `,
 }
 
+// firewallRule is a firewall rule of a stack. Leases are the rules with a time to live
+type firewallRule struct {
+	Id           int       `json:"id"`
+	FromIp       string    `json:"from_ip"`
+	FromGroupId  *int      `json:"from_group_id"`
+	FromServerId *int      `json:"from_server_id"`
+	ToIp         string    `json:"to_ip"`
+	ToGroupId    *int      `json:"to_group_id"`
+	ToServerId   *int      `json:"to_server_id"`
+	Protocol     int       `json:"protocol"`
+	Port         int       `json:"port"`
+	RuleType     string    `json:"rule_type"`
+	Ttl          int       `json:"ttl"`
+	Comments     string    `json:"comments"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// IsLease returns true for the rules that close after a while
+func (r firewallRule) IsLease() bool {
+	return r.Ttl > 0
+}
+
+// ExpiresAt is when the lease closes. Ttl is in minutes
+func (r firewallRule) ExpiresAt() time.Time {
+	return r.UpdatedAt.Add(time.Duration(r.Ttl) * time.Minute)
+}
+
+func (r firewallRule) From() string {
+	switch {
+	case r.FromIp != "":
+		return r.FromIp
+	case r.FromServerId != nil:
+		return fmt.Sprintf("server %d", *r.FromServerId)
+	case r.FromGroupId != nil:
+		return fmt.Sprintf("group %d", *r.FromGroupId)
+	}
+	return "any"
+}
+
+func (r firewallRule) To() string {
+	switch {
+	case r.ToIp != "":
+		return r.ToIp
+	case r.ToServerId != nil:
+		return fmt.Sprintf("server %d", *r.ToServerId)
+	case r.ToGroupId != nil:
+		return fmt.Sprintf("group %d", *r.ToGroupId)
+	}
+	return "all servers"
+}
+
+func buildLease() cli.Command {
+	base := buildBasicCommand()
+	base.Subcommands = []cli.Command{
+		cli.Command{
+			Name:   "list",
+			Action: runListLeases,
+			Usage:  "lists the open leases of a stack",
+			Description: `Lists the firewall leases of a stack that have not expired yet.
+
+Examples:
+$ cx lease list -s mystack
+ID    FROM          TO           PORT  EXPIRES       CREATED
+1234  52.65.34.98   server 521   22    Mar 26 11:43  Mar 26 11:23
+`,
+		},
+		cli.Command{
+			Name:   "revoke",
+			Action: runRevokeLeases,
+			Usage:  "closes open leases of a stack",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "all",
+					Usage: "revoke all the open leases of the stack",
+				},
+			},
+			Description: `Closes the given leases before they expire.
+
+Examples:
+$ cx lease revoke -s mystack 1234
+$ cx lease revoke -s mystack 1234 1235
+$ cx lease revoke -s mystack --all
+`,
+		},
+	}
+
+	return base
+}
+
 func runLease(c *cli.Context) {
+	if len(c.Args()) > 0 {
+		cli.ShowAppHelp(c)
+		os.Exit(2)
+	}
+
 	stack := mustStack(c)
 
 	from := c.String("from")
@@ -58,3 +173,118 @@ func runLease(c *cli.Context) {
 	}
 	printGenericResponse(*genericRes)
 }
+
+func runListLeases(c *cli.Context) {
+	stack := mustStack(c)
+
+	leases, err := listLeases(stack.Uid)
+	must(err)
+
+	if printStructured(leases) {
+		return
+	}
+
+	if len(leases) == 0 {
+		fmt.Println("No open leases.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+	defer w.Flush()
+
+	listRec(w, "ID", "FROM", "TO", "PORT", "EXPIRES", "CREATED")
+	for _, lease := range leases {
+		listRec(w,
+			lease.Id,
+			lease.From(),
+			lease.To(),
+			lease.Port,
+			prettyTime{lease.ExpiresAt()},
+			prettyTime{lease.CreatedAt},
+		)
+	}
+}
+
+func runRevokeLeases(c *cli.Context) {
+	stack := mustStack(c)
+
+	var ids []int
+	if c.Bool("all") {
+		leases, err := listLeases(stack.Uid)
+		must(err)
+		for _, lease := range leases {
+			ids = append(ids, lease.Id)
+		}
+		if len(ids) == 0 {
+			fmt.Println("No open leases.")
+			return
+		}
+	} else {
+		if len(c.Args()) == 0 {
+			cli.ShowSubcommandHelp(c)
+			os.Exit(2)
+		}
+		for _, arg := range c.Args() {
+			id, err := strconv.Atoi(arg)
+			if err != nil {
+				printFatal("Invalid lease id %s", arg)
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	failed := 0
+	for _, id := range ids {
+		if err := revokeFirewallRule(stack.Uid, id); err != nil {
+			printError("Unable to revoke lease %d: %s", id, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Lease %d revoked\n", id)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// listLeases returns the firewall rules of the stack that have not expired yet
+func listLeases(stackUid string) ([]firewallRule, error) {
+	rules, err := listFirewallRules(stackUid)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []firewallRule{}
+	for _, rule := range rules {
+		if rule.IsLease() && rule.ExpiresAt().After(time.Now()) {
+			result = append(result, rule)
+		}
+	}
+	return result, nil
+}
+
+// listFirewallRules and revokeFirewallRule read and delete the firewall rules that Lease of the
+// SDK creates with a POST to the same firewalls endpoint. The SDK has no calls for them yet
+func listFirewallRules(stackUid string) ([]firewallRule, error) {
+	queryStrings := map[string]string{"page": "1"}
+
+	var result []firewallRule
+	for {
+		var p cloud66.Pagination
+		var rules []firewallRule
+		if err := client.Get(&rules, "/stacks/"+stackUid+"/firewalls.json", queryStrings, &p); err != nil {
+			return nil, err
+		}
+		result = append(result, rules...)
+		if p.Current < p.Next {
+			queryStrings["page"] = strconv.Itoa(p.Next)
+		} else {
+			break
+		}
+	}
+	return result, nil
+}
+
+func revokeFirewallRule(stackUid string, id int) error {
+	return client.Delete(fmt.Sprintf("/stacks/%s/firewalls/%d.json", stackUid, id))
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+)
+
+const (
+	// listenHookQueue is how many events of a stack can wait for its hooks. The ones after are dropped
+	listenHookQueue = 100
+	// listenHooksTimeout is how long cx listen waits for the hooks still running when it stops
+	listenHooksTimeout = time.Minute
+)
+
+// listenEventNames are the events hooks can be run on
+var listenEventNames = []string{"message", "error", "deploy-started", "deploy-finished", "deploy-failed"}
+
+// listenEvent is what is passed on to the hooks, as environment variables to the commands and
+// as JSON to the webhooks
+type listenEvent struct {
+	Event        string    `json:"event"`
+	Stack        string    `json:"stack"`
+	StackUid     string    `json:"stack_uid"`
+	Environment  string    `json:"environment"`
+	Severity     string    `json:"severity,omitempty"`
+	Message      string    `json:"message,omitempty"`
+	Time         time.Time `json:"time"`
+	DeployStatus string    `json:"deploy_status,omitempty"`
+}
+
+// Env returns the event as CX_ environment variables
+func (e listenEvent) Env() []string {
+	return []string{
+		"CX_EVENT=" + e.Event,
+		"CX_STACK=" + e.Stack,
+		"CX_STACK_UID=" + e.StackUid,
+		"CX_ENVIRONMENT=" + e.Environment,
+		"CX_SEVERITY=" + e.Severity,
+		"CX_MESSAGE=" + e.Message,
+		"CX_TIME=" + e.Time.Format(time.RFC3339),
+		"CX_DEPLOY_STATUS=" + e.DeployStatus,
+	}
+}
+
+type listenHook struct {
+	event   string
+	command string
+}
+
+// listenHooks runs commands and posts to webhooks on events. The hooks of a stack are run one
+// at a time, in the order of the events
+type listenHooks struct {
+	hooks    []listenHook
+	webhooks []string
+	// events are the events the webhooks are sent. All of them when empty
+	events map[string]bool
+	http   *http.Client
+
+	lock   sync.Mutex
+	queues map[string]chan listenEvent
+	closed bool
+	done   sync.WaitGroup
+}
+
+// newListenHooks takes the --on values, as event=command or only the event to pick what is
+// sent to the webhooks
+func newListenHooks(on []string, webhooks []string) (*listenHooks, error) {
+	if len(on) == 0 && len(webhooks) == 0 {
+		return nil, nil
+	}
+
+	hooks := &listenHooks{
+		webhooks: webhooks,
+		events:   map[string]bool{},
+		http:     &http.Client{Timeout: 30 * time.Second},
+		queues:   map[string]chan listenEvent{},
+	}
+	for _, value := range on {
+		event, command := value, ""
+		if idx := strings.Index(value, "="); idx >= 0 {
+			event, command = value[:idx], strings.TrimSpace(value[idx+1:])
+		}
+		if !isListenEvent(event) {
+			return nil, fmt.Errorf("unknown event %s. Use one of %s", event, strings.Join(listenEventNames, ", "))
+		}
+		if command == "" && len(webhooks) == 0 {
+			return nil, fmt.Errorf("--on %s needs a command (ie. --on '%s=echo $CX_STACK') or a --webhook", event, event)
+		}
+		hooks.events[event] = true
+		if command != "" {
+			hooks.hooks = append(hooks.hooks, listenHook{event: event, command: command})
+		}
+	}
+	return hooks, nil
+}
+
+func isListenEvent(name string) bool {
+	for _, event := range listenEventNames {
+		if event == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fire queues the event to run its commands and post it to the webhooks, after the events of
+// the stack before it. It doesn't block the messages while the hooks are running: the event is
+// dropped when too many of them are waiting already
+func (h *listenHooks) fire(event listenEvent) {
+	if h == nil {
+		return
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.closed {
+		return
+	}
+	queue, ok := h.queues[event.StackUid]
+	if !ok {
+		queue = make(chan listenEvent, listenHookQueue)
+		h.queues[event.StackUid] = queue
+		h.done.Add(1)
+		go func() {
+			defer h.done.Done()
+			for event := range queue {
+				h.run(event)
+			}
+		}()
+	}
+	select {
+	case queue <- event:
+	default:
+		printWarning("The hooks of %s are behind, the %s event is dropped", event.Stack, event.Event)
+	}
+}
+
+// wait stops taking events and returns once the queued ones are done, or false when they are not
+// done in timeout
+func (h *listenHooks) wait(timeout time.Duration) bool {
+	if h == nil {
+		return true
+	}
+
+	h.lock.Lock()
+	if !h.closed {
+		h.closed = true
+		for _, queue := range h.queues {
+			close(queue)
+		}
+	}
+	h.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.done.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// run runs the commands of the event and posts it to the webhooks
+func (h *listenHooks) run(event listenEvent) {
+	for _, hook := range h.hooks {
+		if hook.event == event.Event {
+			runListenHook(hook.command, event)
+		}
+	}
+	if len(h.webhooks) > 0 && (len(h.events) == 0 || h.events[event.Event]) {
+		for _, url := range h.webhooks {
+			h.post(url, event)
+		}
+	}
+}
+
+func runListenHook(command string, event listenEvent) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), event.Env()...)
+	// the output of the hooks doesn't go in the middle of the messages
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		printError("%s hook '%s' failed: %s", event.Event, command, err)
+	}
+}
+
+func (h *listenHooks) post(url string, event listenEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		printError(err.Error())
+		return
+	}
+	resp, err := h.http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		printError("%s webhook %s failed: %s", event.Event, url, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		printError("%s webhook %s failed: %s", event.Event, url, resp.Status)
+	}
+}
+
+// deploymentTracker fires the deploy events of a stack. The realtime messages don't tell when
+// a deployment is over so the stack is checked until it has finished
+type deploymentTracker struct {
+	lock      sync.Mutex
+	deploying bool
+}
+
+// seen is called with each deployment message of the stack
+func (t *deploymentTracker) seen(stack cloud66.Stack, hooks *listenHooks) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.deploying {
+		return
+	}
+	t.deploying = true
+
+	hooks.fire(listenEvent{Event: "deploy-started", Stack: stack.Name, StackUid: stack.Uid, Environment: stack.Environment, Time: time.Now()})
+	go t.wait(stack, hooks)
+}
+
+func (t *deploymentTracker) wait(stack cloud66.Stack, hooks *listenHooks) {
+	defer func() {
+		t.lock.Lock()
+		t.deploying = false
+		t.lock.Unlock()
+	}()
+
+	current, err := client.FindStackByUid(stack.Uid)
+	if err != nil {
+		printError("Unable to check the deployment of %s: %s", stack.Name, err)
+		return
+	}
+	watch := newDeploymentWatch(current, false)
+	interval, timeout := waitSettings(10*time.Second, 3*time.Hour)
+	deadline := time.Now().Add(timeout)
+	for !watch.update(current) {
+		if time.Now().After(deadline) {
+			printError("Gave up waiting for the deployment of %s to finish", stack.Name)
+			return
+		}
+		time.Sleep(interval)
+		// the API might be unavailable for a moment during the deployment
+		if latest, err := client.FindStackByUid(stack.Uid); err == nil {
+			current = latest
+		}
+	}
+
+	event := listenEvent{Event: "deploy-finished", Stack: stack.Name, StackUid: stack.Uid, Environment: stack.Environment, Time: time.Now(), DeployStatus: "success"}
+	if stackDeployFailed(current) {
+		event.DeployStatus = "failed"
+		hooks.fire(event)
+		event.Event = "deploy-failed"
+	}
+	hooks.fire(event)
+}
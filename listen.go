@@ -0,0 +1,89 @@
+package main
+
+import (
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66/cli"
+)
+
+var cmdListen = &Command{
+	Name:  "listen",
+	Build: buildBasicCommand,
+	Run:   runListenStacks,
+	Flags: append([]cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "stack,s",
+			Usage: "full or partial stack name. Repeatable for multiple stacks. All the stacks of the organization by default",
+			Value: &cli.StringSlice{},
+		},
+		cli.StringFlag{
+			Name:  "environment,e",
+			Usage: "only listen to the stacks in this environment",
+		},
+	}, listenFlags...),
+	NeedsStack: false,
+	NeedsOrg:   true,
+	Short:      "shows the deployment logs and events of many stacks",
+	Long: `This shows the live logs of all the stacks of an organization, or the ones given with --stack, in a
+single feed. Each message has the name of its stack in front of it.
+
+The same options as 'cx stacks listen' can be used to pick the messages. With --json each message has the name,
+uid and environment of its stack.
+
+--on event=command runs a command on an event: message, error, deploy-started, deploy-finished or deploy-failed.
+The commands get the event as environment variables: CX_EVENT, CX_STACK, CX_STACK_UID, CX_ENVIRONMENT,
+CX_SEVERITY, CX_MESSAGE, CX_TIME and CX_DEPLOY_STATUS. The commands of a stack run one at a time, in the order
+of the events, and the ones still running are finished before cx exits.
+--webhook posts the events as JSON to a URL, only the ones given with --on (with or without a command) if any.
+
+Examples:
+$ cx listen --org acme --environment production
+$ cx listen --org acme -e production --min-severity error
+$ cx listen -s mystack -s otherstack --deployment-only
+$ cx listen -e production --on 'deploy-finished=notify-send "$CX_STACK" "deployed: $CX_DEPLOY_STATUS"'
+$ cx listen -e production --on 'deploy-started=echo started' --on 'deploy-failed=say "$CX_STACK failed"'
+$ cx listen --org acme --on error --webhook http://localhost:9000/hook
+`,
+}
+
+func runListenStacks(c *cli.Context) {
+	options, err := listenOptionsFrom(c)
+	if err != nil {
+		printFatal(err.Error())
+	}
+
+	if c.String("org") != "" {
+		org := mustOrg(c)
+		client.AccountId = &org.Id
+	}
+
+	stacks := listenTargets(c.StringSlice("stack"), c.String("environment"))
+	if len(stacks) == 0 {
+		printFatal("No stacks found")
+	}
+	listenStacks(stacks, options)
+}
+
+// listenTargets returns the stacks with the given names, or all of them, in the environment
+func listenTargets(names []string, environment string) []cloud66.Stack {
+	if len(names) == 0 {
+		stacks, err := client.StackListWithFilter(environmentFilter(environment, false))
+		must(err)
+		if len(stacks) == 0 {
+			stacks, err = client.StackListWithFilter(environmentFilter(environment, true))
+			must(err)
+		}
+		return stacks
+	}
+
+	var stacks []cloud66.Stack
+	seen := map[string]bool{}
+	for _, name := range names {
+		stack, err := stackByName(name, environment)
+		must(err)
+		if !seen[stack.Uid] {
+			seen[stack.Uid] = true
+			stacks = append(stacks, *stack)
+		}
+	}
+	return stacks
+}
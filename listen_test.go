@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Listen", func() {
+	stacks := []cloud66.Stack{
+		{Uid: "1", Name: "shop", Environment: "production"},
+		{Uid: "2", Name: "shop", Environment: "staging"},
+		{Uid: "3", Name: "blog", Environment: "production"},
+	}
+
+	It("should prefix the messages with the stack name", func() {
+		sources := listenSources(stacks, false)
+		Expect(sources["1"].prefix).To(Equal("shop (production) | "))
+		Expect(sources["2"].prefix).To(Equal("shop (staging)    | "))
+		Expect(sources["3"].prefix).To(Equal("blog              | "))
+
+		Expect(listenSources(stacks[:1], false)["1"].prefix).To(BeEmpty())
+	})
+
+	It("should add the stack to the JSON messages", func() {
+		var fields map[string]interface{}
+		Expect(json.Unmarshal(listenJSON([]byte(`{"severity":4,"message":"failed"}`), stacks[2]), &fields)).To(Succeed())
+		Expect(fields).To(HaveKeyWithValue("stack", "blog"))
+		Expect(fields).To(HaveKeyWithValue("stack_uid", "3"))
+		Expect(fields).To(HaveKeyWithValue("message", "failed"))
+	})
+
+	It("should read the events and commands of --on", func() {
+		hooks, err := newListenHooks([]string{"deploy-started=echo started", "deploy-finished=echo a=b"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hooks.hooks).To(Equal([]listenHook{{"deploy-started", "echo started"}, {"deploy-finished", "echo a=b"}}))
+
+		_, err = newListenHooks([]string{"deploy-started"}, nil)
+		Expect(err).To(HaveOccurred())
+		_, err = newListenHooks([]string{"deployed=echo deployed"}, nil)
+		Expect(err).To(HaveOccurred())
+
+		hooks, err = newListenHooks([]string{"error"}, []string{"http://localhost:9000/hook"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hooks.hooks).To(BeEmpty())
+		Expect(hooks.events).To(HaveKey("error"))
+
+		hooks, err = newListenHooks(nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hooks).To(BeNil())
+	})
+
+	It("should run the hooks of a stack in order and finish them on wait", func() {
+		out, err := ioutil.TempFile("", "cx-hooks")
+		Expect(err).NotTo(HaveOccurred())
+		out.Close()
+		defer os.Remove(out.Name())
+
+		hooks, err := newListenHooks([]string{"message=sleep 0.1; echo $CX_MESSAGE >> " + out.Name()}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		hooks.fire(listenEvent{Event: "message", StackUid: "1", Message: "first"})
+		hooks.fire(listenEvent{Event: "message", StackUid: "1", Message: "second"})
+		Expect(hooks.wait(time.Minute)).To(BeTrue())
+		hooks.fire(listenEvent{Event: "message", StackUid: "1", Message: "too late"})
+
+		Expect(ioutil.ReadFile(out.Name())).To(Equal([]byte("first\nsecond\n")))
+	})
+
+	It("should not block on hooks that don't finish", func() {
+		hooks, err := newListenHooks([]string{"message=sleep 1"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		fired := make(chan struct{})
+		go func() {
+			for i := 0; i < listenHookQueue+2; i++ {
+				hooks.fire(listenEvent{Event: "message", Stack: "shop", StackUid: "1"})
+			}
+			close(fired)
+		}()
+		Eventually(fired).Should(BeClosed())
+		Expect(hooks.wait(100 * time.Millisecond)).To(BeFalse())
+	})
+
+	It("should post the events of --on to the webhooks", func() {
+		received := make(chan listenEvent, 2)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var event listenEvent
+			json.NewDecoder(r.Body).Decode(&event)
+			received <- event
+		}))
+		defer server.Close()
+
+		hooks, err := newListenHooks([]string{"error"}, []string{server.URL})
+		Expect(err).NotTo(HaveOccurred())
+		hooks.http = server.Client()
+
+		hooks.fire(listenEvent{Event: "message", Stack: "blog", Message: "started"})
+		hooks.fire(listenEvent{Event: "error", Stack: "blog", Message: "failed"})
+
+		var event listenEvent
+		Eventually(received).Should(Receive(&event))
+		Expect(event.Event).To(Equal("error"))
+		Expect(event.Message).To(Equal("failed"))
+		Consistently(received).ShouldNot(Receive())
+	})
+})
@@ -51,6 +51,8 @@ var commands = []*Command{
 	cmdStacks,
 	cmdLogin,
 	cmdRedeploy,
+	cmdListen,
+	cmdActions,
 	cmdOpen,
 	cmdSettings,
 	cmdEasyDeploy,
@@ -60,6 +62,7 @@ var commands = []*Command{
 	cmdTunnel,
 	cmdServers,
 	cmdSsh,
+	cmdSshConfig,
 	cmdTail,
 	cmdUpload,
 	cmdDownload,
@@ -206,6 +209,26 @@ func beforeCommand(c *cli.Context) error {
 	}
 
 	debugMode = c.GlobalBool("debug")
+	flagNoWait = c.GlobalBool("no-wait")
+	flagTimeout = c.GlobalDuration("timeout")
+	flagPollInterval = c.GlobalDuration("poll-interval")
+
+	if err := setOutputFormat(c.GlobalString("output")); err != nil {
+		return err
+	}
+	if err := setOutputTemplate(c.GlobalString("format")); err != nil {
+		return err
+	}
+	setOutputColumns(c.GlobalString("columns"))
+	if c.GlobalString("gateway-key") != "" {
+		gatewayKeyFile = expandPath(c.GlobalString("gateway-key"))
+	}
+	if outputTemplate != nil && len(outputColumns) > 0 {
+		return fmt.Errorf("--format and --columns cannot be used together")
+	}
+	if (outputTemplate != nil || len(outputColumns) > 0) && outputFormat != outputTable {
+		return fmt.Errorf("--format and --columns cannot be used with --output %s", outputFormat)
+	}
 
 	var command string
 	if len(c.Args()) >= 1 {
@@ -245,6 +268,40 @@ func setGlobals(app *cli.App) {
 			Usage:  "run in debug mode",
 			EnvVar: "CXDEBUG",
 		},
+		cli.BoolFlag{
+			Name:  "no-wait",
+			Usage: "print the id of the action started by a command and return without waiting for it (see cx help actions)",
+		},
+		cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "how long to wait for the actions started by commands (ie. 30m). Each command has its own default",
+		},
+		cli.DurationFlag{
+			Name:  "poll-interval",
+			Usage: "how often to check the actions started by commands while waiting for them (ie. 10s)",
+		},
+		cli.StringFlag{
+			Name:   "output",
+			Usage:  "output format for list and show commands (table|json|yaml|jsonpath=<template>, ie. jsonpath='{.items[*].address}')",
+			Value:  "table",
+			EnvVar: "CXOUTPUT",
+		},
+		cli.StringFlag{
+			Name:   "gateway-key",
+			Usage:  "path to the key of the deploy gateway (bastion server) for servers behind one",
+			Value:  "",
+			EnvVar: "CXGATEWAYKEY",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Usage: "pretty-print list and show commands using a Go template (ie. '{{.Name}}\\t{{.Address}}')",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name:  "columns",
+			Usage: "comma separated list of columns to show in list and show commands (ie. name,address,roles)",
+			Value: "",
+		},
 	}
 }
 
@@ -366,17 +423,26 @@ func recoverPanic() {
 }
 
 func filterByEnvironmentExact(item interface{}) bool {
-	if flagEnvironment == "" {
-		return true
-	}
-	return strings.ToLower(item.(cloud66.Stack).Environment) == strings.ToLower(flagEnvironment)
+	return environmentFilter(flagEnvironment, false)(item)
 }
 
 func filterByEnvironmentFuzzy(item interface{}) bool {
-	if flagEnvironment == "" {
-		return true
+	return environmentFilter(flagEnvironment, true)(item)
+}
+
+// environmentFilter returns a stack filter for the full environment name or, when fuzzy, its
+// starting characters. All the stacks pass an empty environment
+func environmentFilter(environment string, fuzzy bool) func(item interface{}) bool {
+	return func(item interface{}) bool {
+		if environment == "" {
+			return true
+		}
+		stackEnvironment := strings.ToLower(item.(cloud66.Stack).Environment)
+		if fuzzy {
+			return strings.HasPrefix(stackEnvironment, strings.ToLower(environment))
+		}
+		return stackEnvironment == strings.ToLower(environment)
 	}
-	return strings.HasPrefix(strings.ToLower(item.(cloud66.Stack).Environment), strings.ToLower(flagEnvironment))
 }
 
 func org(c *cli.Context) (*cloud66.Account, error) {
@@ -428,36 +494,14 @@ func stack(c *cli.Context) (*cloud66.Stack, error) {
 
 	var err error
 	if c.String("stack") != "" {
-		stacks, err := client.StackListWithFilter(filterByEnvironmentExact)
+		flagStack, err = stackByName(c.String("stack"), flagEnvironment)
 		if err != nil {
 			return nil, err
 		}
-		var stackNames []string
-		for _, stack := range stacks {
-			stackNames = append(stackNames, stack.Name)
-		}
-		idx, err := fuzzyFind(stackNames, c.String("stack"), false)
-		if err != nil {
-			// try fuzzy env match
-			stacks, err = client.StackListWithFilter(filterByEnvironmentFuzzy)
-			if err != nil {
-				return nil, err
-			}
-			var stackFuzzNames []string
-			for _, stack := range stacks {
-				stackFuzzNames = append(stackFuzzNames, stack.Name)
-			}
-			idx, err = fuzzyFind(stackFuzzNames, c.String("stack"), false)
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		flagStack = &stacks[idx]
 
 		// toSdout is of type []bool. Take first value
-		if c.String("environment") != "" {
-			fmt.Printf("(%s)\n", flagStack.Environment)
+		if c.String("environment") != "" && !structuredOutput() {
+			fmt.Fprintf(os.Stderr, "(%s)\n", flagStack.Environment)
 		}
 
 		return flagStack, err
@@ -472,6 +516,36 @@ func stack(c *cli.Context) (*cloud66.Stack, error) {
 	return stackFromGitRemote(remoteGitUrl(), localGitBranch())
 }
 
+// stackByName finds the stack by its full or partial name in the full or partial environment
+func stackByName(name string, environment string) (*cloud66.Stack, error) {
+	stacks, err := client.StackListWithFilter(environmentFilter(environment, false))
+	if err != nil {
+		return nil, err
+	}
+	var stackNames []string
+	for _, stack := range stacks {
+		stackNames = append(stackNames, stack.Name)
+	}
+	idx, err := fuzzyFind(stackNames, name, false)
+	if err != nil {
+		// try fuzzy env match
+		stacks, err = client.StackListWithFilter(environmentFilter(environment, true))
+		if err != nil {
+			return nil, err
+		}
+		var stackFuzzNames []string
+		for _, stack := range stacks {
+			stackFuzzNames = append(stackFuzzNames, stack.Name)
+		}
+		idx, err = fuzzyFind(stackFuzzNames, name, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &stacks[idx], nil
+}
+
 func mustStack(c *cli.Context) *cloud66.Stack {
 	stack, err := stack(c)
 	if err != nil {
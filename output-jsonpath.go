@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	jsonPathText  = "text"
+	jsonPathValue = "value"
+	jsonPathRange = "range"
+)
+
+// outputJSONPathTemplate is set from --output jsonpath=<template>
+var outputJSONPathTemplate []jsonPathNode
+
+// jsonPathNode is a part of a --output jsonpath template: some text, a path whose values are
+// printed or a range over the values of a path
+type jsonPathNode struct {
+	kind string
+	text string
+	path jsonPath
+	// nodes are the ones between range and end
+	nodes []jsonPathNode
+}
+
+// jsonPath is a path like .items[*].address. Paths starting with $ are from the root, the
+// others from the item of the range they are in
+type jsonPath struct {
+	fromRoot bool
+	steps    []jsonPathStep
+}
+
+type jsonPathStep struct {
+	name     string
+	index    *int
+	wildcard bool
+}
+
+// parseJSONPathTemplate parses a template like kubectl -o jsonpath, with the paths in braces:
+// {.items[*].name}, {range .items[*]}{.name}{"\n"}{end}. \t and \n are accepted as escapes like
+// with --format
+func parseJSONPathTemplate(template string) ([]jsonPathNode, error) {
+	template = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(template)
+
+	var tokens []string
+	for len(template) > 0 {
+		start := strings.Index(template, "{")
+		if start < 0 {
+			tokens = append(tokens, template)
+			break
+		}
+		if start > 0 {
+			tokens = append(tokens, template[:start])
+		}
+		end := strings.Index(template[start:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed { in %s", template[start:])
+		}
+		tokens = append(tokens, template[start:start+end+1])
+		template = template[start+end+1:]
+	}
+
+	nodes, rest, err := parseJSONPathNodes(tokens, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("{end} without {range}")
+	}
+	return nodes, nil
+}
+
+// parseJSONPathNodes parses the tokens up to the {end} of a range and returns the ones after it
+func parseJSONPathNodes(tokens []string, inRange bool) ([]jsonPathNode, []string, error) {
+	var nodes []jsonPathNode
+	for len(tokens) > 0 {
+		token := tokens[0]
+		tokens = tokens[1:]
+		if !strings.HasPrefix(token, "{") {
+			nodes = append(nodes, jsonPathNode{kind: jsonPathText, text: token})
+			continue
+		}
+
+		action := strings.TrimSpace(token[1 : len(token)-1])
+		switch {
+		case action == "end":
+			if !inRange {
+				return nil, nil, fmt.Errorf("{end} without {range}")
+			}
+			return nodes, tokens, nil
+		case strings.HasPrefix(action, "range "):
+			path, err := parseJSONPath(strings.TrimPrefix(action, "range "))
+			if err != nil {
+				return nil, nil, err
+			}
+			var inner []jsonPathNode
+			inner, tokens, err = parseJSONPathNodes(tokens, true)
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes = append(nodes, jsonPathNode{kind: jsonPathRange, path: path, nodes: inner})
+		case len(action) >= 2 && (action[0] == '"' || action[0] == '\'') && action[len(action)-1] == action[0]:
+			nodes = append(nodes, jsonPathNode{kind: jsonPathText, text: action[1 : len(action)-1]})
+		default:
+			path, err := parseJSONPath(action)
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes = append(nodes, jsonPathNode{kind: jsonPathValue, path: path})
+		}
+	}
+	if inRange {
+		return nil, nil, fmt.Errorf("{range} without {end}")
+	}
+	return nodes, nil, nil
+}
+
+func parseJSONPath(expr string) (jsonPath, error) {
+	var path jsonPath
+	rest := strings.TrimSpace(expr)
+	switch {
+	case strings.HasPrefix(rest, "$"):
+		path.fromRoot = true
+		rest = rest[1:]
+	case strings.HasPrefix(rest, "@"):
+		rest = rest[1:]
+	}
+
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			rest = rest[end:]
+			switch {
+			case name == "*":
+				path.steps = append(path.steps, jsonPathStep{wildcard: true})
+			case name != "":
+				path.steps = append(path.steps, jsonPathStep{name: name})
+			case strings.HasPrefix(rest, "."):
+				return path, fmt.Errorf("recursive descent (..) is not supported in %s", expr)
+			}
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return path, fmt.Errorf("unclosed [ in %s", expr)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				path.steps = append(path.steps, jsonPathStep{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				path.steps = append(path.steps, jsonPathStep{name: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return path, fmt.Errorf("unsupported [%s] in %s. Use an index, * or a quoted name", inner, expr)
+				}
+				path.steps = append(path.steps, jsonPathStep{index: &index})
+			}
+		default:
+			return path, fmt.Errorf("invalid path %s. Paths start with . (ie. {.items[*].name})", expr)
+		}
+	}
+	return path, nil
+}
+
+// values returns the values the path selects from root or current
+func (p jsonPath) values(root interface{}, current interface{}) ([]interface{}, error) {
+	values := []interface{}{current}
+	if p.fromRoot {
+		values = []interface{}{root}
+	}
+
+	for _, step := range p.steps {
+		var next []interface{}
+		for _, value := range values {
+			switch v := value.(type) {
+			case map[string]interface{}:
+				switch {
+				case step.wildcard:
+					keys := make([]string, 0, len(v))
+					for key := range v {
+						keys = append(keys, key)
+					}
+					sort.Strings(keys)
+					for _, key := range keys {
+						next = append(next, v[key])
+					}
+				case step.name != "":
+					item, ok := v[step.name]
+					if !ok {
+						return nil, fmt.Errorf("%s is not found", step.name)
+					}
+					next = append(next, item)
+				}
+			case []interface{}:
+				switch {
+				case step.wildcard:
+					next = append(next, v...)
+				case step.index != nil:
+					index := *step.index
+					if index < 0 {
+						index += len(v)
+					}
+					if index >= 0 && index < len(v) {
+						next = append(next, v[index])
+					}
+				}
+			}
+		}
+		values = next
+	}
+	return values, nil
+}
+
+// printJSONPath prints the value with the --output jsonpath template. Lists are the items
+// of an object like the lists of kubectl, so {.items[*].name} works the same
+func printJSONPath(value interface{}) {
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Slice && v.IsNil() {
+		value = []interface{}{}
+	}
+	buf, err := json.Marshal(value)
+	must(err)
+
+	var root interface{}
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	// numbers are printed as they are in the json and not as floats
+	decoder.UseNumber()
+	must(decoder.Decode(&root))
+	if items, ok := root.([]interface{}); ok {
+		root = map[string]interface{}{"items": items}
+	}
+
+	var out strings.Builder
+	if err := executeJSONPath(&out, outputJSONPathTemplate, root, root); err != nil {
+		printFatal("Failed to apply the jsonpath template: %s", err)
+	}
+	text := out.String()
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	fmt.Print(text)
+}
+
+func executeJSONPath(out *strings.Builder, nodes []jsonPathNode, root interface{}, current interface{}) error {
+	for _, node := range nodes {
+		switch node.kind {
+		case jsonPathText:
+			out.WriteString(node.text)
+		case jsonPathValue:
+			values, err := node.path.values(root, current)
+			if err != nil {
+				return err
+			}
+			var texts []string
+			for _, value := range values {
+				texts = append(texts, jsonPathString(value))
+			}
+			out.WriteString(strings.Join(texts, " "))
+		case jsonPathRange:
+			values, err := node.path.values(root, current)
+			if err != nil {
+				return err
+			}
+			for _, value := range values {
+				if err := executeJSONPath(out, node.nodes, root, value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// jsonPathString prints strings and numbers as they are and objects and lists as json
+func jsonPathString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(buf)
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	outputTable    = "table"
+	outputJSON     = "json"
+	outputYAML     = "yaml"
+	outputJSONPath = "jsonpath"
+)
+
+// outputFormat is set from the global --output flag
+var outputFormat = outputTable
+
+// outputTemplate and outputColumns are set from the global --format and --columns flags
+var outputTemplate *template.Template
+var outputColumns []string
+
+var outputTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		buf, err := json.Marshal(v)
+		return string(buf), err
+	},
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+func setOutputFormat(format string) error {
+	outputJSONPathTemplate = nil
+	// the template of jsonpath=<template> keeps its case
+	if idx := strings.Index(format, "="); idx >= 0 && strings.ToLower(format[:idx]) == outputJSONPath {
+		nodes, err := parseJSONPathTemplate(format[idx+1:])
+		if err != nil {
+			return fmt.Errorf("invalid jsonpath template: %s", err)
+		}
+		outputFormat = outputJSONPath
+		outputJSONPathTemplate = nodes
+		return nil
+	}
+
+	switch strings.ToLower(format) {
+	case "", outputTable:
+		outputFormat = outputTable
+	case outputJSON:
+		outputFormat = outputJSON
+	case outputYAML:
+		outputFormat = outputYAML
+	default:
+		return fmt.Errorf("unsupported output format '%s'. Valid formats are table, json, yaml and jsonpath=<template>", format)
+	}
+	return nil
+}
+
+// setOutputTemplate parses the Go template given with --format. \t and \n are
+// accepted as escapes so the template can be passed in single quotes
+func setOutputTemplate(format string) error {
+	outputTemplate = nil
+	if format == "" {
+		return nil
+	}
+
+	format = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+	tmpl, err := template.New("format").Funcs(outputTemplateFuncs).Parse(format)
+	if err != nil {
+		return fmt.Errorf("invalid format template: %s", err)
+	}
+	outputTemplate = tmpl
+	return nil
+}
+
+// setOutputColumns takes the comma separated list of columns given with --columns
+func setOutputColumns(columns string) {
+	outputColumns = nil
+	for _, column := range strings.Split(columns, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			outputColumns = append(outputColumns, column)
+		}
+	}
+}
+
+// structuredOutput returns true if the user asked for machine readable output
+func structuredOutput() bool {
+	return outputFormat != outputTable || outputTemplate != nil || len(outputColumns) > 0
+}
+
+// printStructured writes the given value to stdout in the format selected with --output,
+// --format or --columns. It returns false if the default table output is selected and
+// nothing has been printed, in which case the caller should carry on with its own table printer.
+func printStructured(value interface{}) bool {
+	if !structuredOutput() {
+		return false
+	}
+
+	if outputTemplate != nil {
+		printTemplate(value)
+		return true
+	}
+
+	if len(outputColumns) > 0 {
+		printColumns(value)
+		return true
+	}
+
+	if outputFormat == outputJSONPath {
+		printJSONPath(value)
+		return true
+	}
+
+	// an empty list should show up as an empty list and not as null
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Slice && v.IsNil() {
+		value = []interface{}{}
+	}
+
+	// marshal through json first so the field names follow the API json tags in both formats
+	buf, err := json.MarshalIndent(value, "", "  ")
+	must(err)
+
+	if outputFormat == outputJSON {
+		fmt.Println(string(buf))
+		return true
+	}
+
+	var generic interface{}
+	must(yaml.Unmarshal(buf, &generic))
+	buf, err = yaml.Marshal(generic)
+	must(err)
+	fmt.Print(string(buf))
+
+	return true
+}
+
+// printTemplate runs the --format template once for each item of the list
+func printTemplate(value interface{}) {
+	for _, item := range outputItems(value) {
+		var b strings.Builder
+		if err := outputTemplate.Execute(&b, item.Interface()); err != nil {
+			printFatal("Failed to apply the format template: %s", err)
+		}
+		line := b.String()
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		fmt.Print(line)
+	}
+}
+
+// printColumns prints a table of only the columns given with --columns. Columns
+// are matched against the field names, the API json names and any methods of the items.
+// Nested values can be selected with a dot (ie. notifications.reboot_required)
+func printColumns(value interface{}) {
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+	defer w.Flush()
+
+	var header []interface{}
+	for _, column := range outputColumns {
+		header = append(header, strings.ToUpper(column))
+	}
+	listRec(w, header...)
+
+	for _, item := range outputItems(value) {
+		var rec []interface{}
+		for _, column := range outputColumns {
+			v, ok := columnValue(item, column)
+			if !ok {
+				printFatal("Unknown column '%s'", column)
+			}
+			rec = append(rec, formatColumn(v))
+		}
+		listRec(w, rec...)
+	}
+}
+
+// outputItems returns the items of a list, or the value itself if it is not a list
+func outputItems(value interface{}) []reflect.Value {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return []reflect.Value{v}
+	}
+
+	items := make([]reflect.Value, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		items[i] = v.Index(i)
+	}
+	return items
+}
+
+func columnValue(item reflect.Value, column string) (reflect.Value, bool) {
+	for _, part := range strings.Split(column, ".") {
+		var ok bool
+		if item, ok = columnPart(item, part); !ok {
+			return item, false
+		}
+	}
+	return item, true
+}
+
+func columnPart(item reflect.Value, name string) (reflect.Value, bool) {
+	// methods first as they can be defined on the pointer
+	for item.IsValid() && (item.Kind() == reflect.Ptr || item.Kind() == reflect.Interface) {
+		if m, ok := columnMethod(item, name); ok {
+			return m, true
+		}
+		item = item.Elem()
+	}
+	if !item.IsValid() {
+		return item, false
+	}
+
+	switch item.Kind() {
+	case reflect.Struct:
+		t := item.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+			if sameColumn(field.Name, name) || (jsonName != "" && sameColumn(jsonName, name)) {
+				return item.Field(i), true
+			}
+		}
+	case reflect.Map:
+		if item.Type().Key().Kind() == reflect.String {
+			for _, key := range item.MapKeys() {
+				if sameColumn(key.String(), name) {
+					return item.MapIndex(key), true
+				}
+			}
+		}
+	}
+
+	return columnMethod(item, name)
+}
+
+// columnMethod calls a method with no arguments and a single result, like Server.Health
+func columnMethod(item reflect.Value, name string) (reflect.Value, bool) {
+	t := item.Type()
+	for i := 0; i < t.NumMethod(); i++ {
+		m := item.Method(i)
+		if m.Type().NumIn() == 0 && m.Type().NumOut() == 1 && sameColumn(t.Method(i).Name, name) {
+			return m.Call(nil)[0], true
+		}
+	}
+	return item, false
+}
+
+// sameColumn compares names ignoring case and separators so address, Address
+// and server_roles, ServerRoles all match
+func sameColumn(a, b string) bool {
+	normalize := strings.NewReplacer("_", "", "-", "")
+	return strings.EqualFold(normalize.Replace(a), normalize.Replace(b))
+}
+
+func formatColumn(v reflect.Value) interface{} {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return ""
+	}
+
+	switch x := v.Interface().(type) {
+	case time.Time:
+		return prettyTime{x}
+	case []string:
+		return strings.Join(x, ",")
+	}
+	return v.Interface()
+}
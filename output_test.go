@@ -0,0 +1,102 @@
+package main
+
+import (
+	"github.com/cloud66-oss/cloud66"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Structured output", func() {
+	servers := []cloud66.Server{
+		cloud66.Server{Name: "lion", Address: "1.2.3.4", Roles: []string{"web", "app"}},
+	}
+
+	AfterEach(func() {
+		Expect(setOutputFormat("table")).To(Succeed())
+		Expect(setOutputTemplate("")).To(Succeed())
+		setOutputColumns("")
+	})
+
+	It("should reject unknown formats", func() {
+		Expect(setOutputFormat("xml")).NotTo(Succeed())
+	})
+
+	It("should leave the table output to the caller", func() {
+		Expect(setOutputFormat("")).To(Succeed())
+		Expect(printStructured(servers)).To(BeFalse())
+	})
+
+	It("should use the api field names in json", func() {
+		Expect(setOutputFormat("json")).To(Succeed())
+
+		StartCaptureStdout()
+		Expect(printStructured(servers)).To(BeTrue())
+		output := StopCaptureStdout()
+
+		Expect(output[0]).To(Equal("["))
+		Expect(output).To(ContainElement(`    "name": "lion",`))
+		Expect(output).To(ContainElement(`    "server_roles": [`))
+	})
+
+	It("should use the api field names in yaml", func() {
+		Expect(setOutputFormat("YAML")).To(Succeed())
+
+		StartCaptureStdout()
+		Expect(printStructured(servers)).To(BeTrue())
+		output := StopCaptureStdout()
+
+		Expect(output).To(ContainElement("  name: lion"))
+		Expect(output).To(ContainElement("  - web"))
+	})
+
+	It("should print empty lists as empty lists", func() {
+		Expect(setOutputFormat("json")).To(Succeed())
+
+		StartCaptureStdout()
+		var none []cloud66.Server
+		Expect(printStructured(none)).To(BeTrue())
+		output := StopCaptureStdout()
+
+		Expect(output[0]).To(Equal("[]"))
+	})
+
+	It("should apply the format template to each item", func() {
+		Expect(setOutputTemplate(`{{.Name}}\t{{.Address}} {{join .Roles ","}}`)).To(Succeed())
+
+		StartCaptureStdout()
+		Expect(printStructured(servers)).To(BeTrue())
+		output := StopCaptureStdout()
+
+		Expect(output[0]).To(Equal("lion\t1.2.3.4 web,app"))
+	})
+
+	It("should reject broken templates", func() {
+		Expect(setOutputTemplate("{{.Name")).NotTo(Succeed())
+	})
+
+	It("should only print the selected columns", func() {
+		setOutputColumns("address, server_roles,health")
+
+		StartCaptureStdout()
+		Expect(printStructured(servers)).To(BeTrue())
+		output := StopCaptureStdout()
+
+		Expect(output[0]).To(MatchRegexp(`^ADDRESS\s+SERVER_ROLES\s+HEALTH$`))
+		Expect(output[1]).To(MatchRegexp(`^1\.2\.3\.4\s+web,app\s+\S+$`))
+	})
+
+	It("should print the paths of the jsonpath template", func() {
+		Expect(setOutputFormat(`jsonpath={range .items[*]}{.name} {.server_roles[0]}{"\n"}{end}`)).To(Succeed())
+
+		StartCaptureStdout()
+		Expect(printStructured(servers)).To(BeTrue())
+		output := StopCaptureStdout()
+
+		Expect(output[0]).To(Equal("lion web"))
+	})
+
+	It("should reject broken jsonpath templates", func() {
+		Expect(setOutputFormat("jsonpath={range .items[*]}{.name}")).NotTo(Succeed())
+		Expect(setOutputFormat("jsonpath={name}")).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// prefixWriter writes each line with a prefix (ie. the server name) so the output of
+// several servers can be told apart. Writers that share the same lock never interleave
+// their lines. Lines are dropped if filter is set and returns false for them
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	lock   *sync.Mutex
+	buf    []byte
+	filter func(line []byte) bool
+}
+
+func newPrefixWriter(w io.Writer, prefix string, lock *sync.Mutex) *prefixWriter {
+	return &prefixWriter{w: w, prefix: prefix, lock: lock}
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.buf = append(p.buf, data...)
+	for {
+		idx := bytes.IndexByte(p.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		if err := p.writeLine(p.buf[:idx+1]); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[idx+1:]
+	}
+	return len(data), nil
+}
+
+// Flush writes what is left of an unfinished line
+func (p *prefixWriter) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	err := p.writeLine(append(p.buf, '\n'))
+	p.buf = nil
+	return err
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	if p.filter != nil && !p.filter(line) {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	_, err := p.w.Write(append([]byte(p.prefix), line...))
+	return err
+}
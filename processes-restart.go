@@ -42,6 +42,9 @@ func runProcessRestart(c *cli.Context) {
 		printFatal(err.Error())
 	}
 	genericRes, err := endProcessAction(*asyncId, stack.Uid)
+	if actionNotWaited(err, *asyncId) {
+		return
+	}
 	if err != nil {
 		printFatal(err.Error())
 	}
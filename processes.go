@@ -153,7 +153,9 @@ func runProcesses(c *cli.Context) {
 		if !server.HasRole("app") || server.HasRole("docker") || server.HasRole("kubes") {
 			printFatal("Server '" + flagServer + "' can not host processes")
 		}
-		fmt.Printf("Server: %s\n", server.Name)
+		if !structuredOutput() {
+			fmt.Printf("Server: %s\n", server.Name)
+		}
 		serverUid = &server.Uid
 	}
 
@@ -166,7 +168,9 @@ func runProcesses(c *cli.Context) {
 		processes, err = client.GetProcesses(stack.Uid, serverUid)
 		must(err)
 	} else {
-		fmt.Printf("Process: %s\n", flagName)
+		if !structuredOutput() {
+			fmt.Printf("Process: %s\n", flagName)
+		}
 		process, err := client.GetProcess(stack.Uid, flagName, serverUid)
 		must(err)
 		if process == nil {
@@ -180,6 +184,11 @@ func runProcesses(c *cli.Context) {
 }
 
 func printProcessesList(w io.Writer, processes []cloud66.Process) {
+	sort.Sort(ProcessByNameServer(processes))
+	if printStructured(processes) {
+		return
+	}
+
 	listRec(w,
 		"NAME",
 		"COMMAND",
@@ -187,7 +196,6 @@ func printProcessesList(w io.Writer, processes []cloud66.Process) {
 		"COUNT",
 	)
 
-	sort.Sort(ProcessByNameServer(processes))
 	for _, a := range processes {
 		listProcess(w, a)
 	}
@@ -219,7 +227,7 @@ func startProcessAction(stackUid string, processName *string, serverUid *string,
 }
 
 func endProcessAction(asyncId int, stackUid string) (*cloud66.GenericResponse, error) {
-	return client.WaitStackAsyncAction(asyncId, stackUid, 5*time.Second, 10*time.Minute, true)
+	return waitStackAction(asyncId, stackUid, 5*time.Second, 10*time.Minute, true)
 }
 
 type ProcessByNameServer []cloud66.Process
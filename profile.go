@@ -16,6 +16,9 @@ type Profile struct {
 	Organization string `json:"organization" yaml:"organization"`
 	Name         string `json:"name" yaml:"name"`
 	TokenFile    string `json:"token_file" yaml:"token_file"`
+
+	// Tunnels are the tunnel presets of the profile (see cx tunnel --save)
+	Tunnels map[string]*TunnelPreset `json:"tunnels,omitempty" yaml:"tunnels,omitempty"`
 }
 
 type Profiles struct {
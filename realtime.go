@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// realtimeMaxBackoff is the longest wait between two reconnects
+	realtimeMaxBackoff = 30 * time.Second
+	// realtimePollTimeout is longer than the time the server holds a long-polling request
+	realtimePollTimeout = 90 * time.Second
+)
+
+// realtimeEvent is a message published on one of the subscribed channels
+type realtimeEvent struct {
+	Channel string
+	Data    []byte
+}
+
+// realtimeClient is a Bayeux (Faye) long-polling client that subscribes to channels and
+// reconnects, with a new handshake, whenever the connection drops
+type realtimeClient struct {
+	endpoint string
+	channels []string
+	clientId string
+	http     *http.Client
+	// status is called with connection changes. It is not called when nil
+	status func(string)
+}
+
+type bayeuxMessage struct {
+	Id                       string          `json:"id,omitempty"`
+	Channel                  string          `json:"channel"`
+	ClientId                 string          `json:"clientId,omitempty"`
+	Version                  string          `json:"version,omitempty"`
+	SupportedConnectionTypes []string        `json:"supportedConnectionTypes,omitempty"`
+	ConnectionType           string          `json:"connectionType,omitempty"`
+	Subscription             string          `json:"subscription,omitempty"`
+	Successful               *bool           `json:"successful,omitempty"`
+	Error                    string          `json:"error,omitempty"`
+	Data                     json.RawMessage `json:"data,omitempty"`
+}
+
+func newRealtimeClient(endpoint string, channels ...string) *realtimeClient {
+	return &realtimeClient{
+		endpoint: endpoint,
+		channels: channels,
+		http:     &http.Client{Timeout: realtimePollTimeout},
+	}
+}
+
+// Listen sends the events of the subscribed channels to events until stop is closed
+func (r *realtimeClient) Listen(events chan<- realtimeEvent, stop <-chan struct{}) {
+	backoff := time.Second
+	connected := false
+	for {
+		err := r.connect()
+		if err == nil {
+			if connected {
+				r.report("Reconnected")
+			}
+			connected = true
+			backoff = time.Second
+			err = r.poll(events, stop)
+		}
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		if connected {
+			r.report(fmt.Sprintf("Connection lost (%s). Reconnecting in %s", err, backoff))
+		} else {
+			r.report(fmt.Sprintf("Unable to connect (%s). Retrying in %s", err, backoff))
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > realtimeMaxBackoff {
+			backoff = realtimeMaxBackoff
+		}
+	}
+}
+
+// connect does the handshake and subscribes to the channels
+func (r *realtimeClient) connect() error {
+	replies, err := r.send(bayeuxMessage{
+		Channel:                  "/meta/handshake",
+		Version:                  "1.0",
+		SupportedConnectionTypes: []string{"long-polling"},
+	})
+	if err != nil {
+		return err
+	}
+	handshake, err := metaReply(replies, "/meta/handshake")
+	if err != nil {
+		return err
+	}
+	r.clientId = handshake.ClientId
+
+	for _, channel := range r.channels {
+		replies, err := r.send(bayeuxMessage{Channel: "/meta/subscribe", ClientId: r.clientId, Subscription: channel})
+		if err != nil {
+			return err
+		}
+		if _, err := metaReply(replies, "/meta/subscribe"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// poll keeps a long-polling request open to receive the events. It only returns when the
+// connection fails or stop is closed
+func (r *realtimeClient) poll(events chan<- realtimeEvent, stop <-chan struct{}) error {
+	for {
+		replies, err := r.send(bayeuxMessage{Channel: "/meta/connect", ClientId: r.clientId, ConnectionType: "long-polling"})
+		if err != nil {
+			return err
+		}
+
+		for _, reply := range replies {
+			if reply.Channel == "/meta/connect" || len(reply.Data) == 0 {
+				continue
+			}
+			select {
+			case events <- realtimeEvent{Channel: reply.Channel, Data: reply.Data}:
+			case <-stop:
+				return nil
+			}
+		}
+		// the client is unknown after a restart of the server and needs a new handshake
+		if _, err := metaReply(replies, "/meta/connect"); err != nil {
+			return err
+		}
+
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+	}
+}
+
+func (r *realtimeClient) send(message bayeuxMessage) ([]bayeuxMessage, error) {
+	body, err := json.Marshal([]bayeuxMessage{message})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.http.Post(r.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var replies []bayeuxMessage
+	if err := json.Unmarshal(body, &replies); err != nil {
+		return nil, fmt.Errorf("invalid response: %s", err)
+	}
+	return replies, nil
+}
+
+func (r *realtimeClient) report(status string) {
+	if r.status != nil {
+		r.status(status)
+	}
+}
+
+// metaReply finds the reply to a meta message and returns an error if it was unsuccessful
+func metaReply(replies []bayeuxMessage, channel string) (*bayeuxMessage, error) {
+	for _, reply := range replies {
+		if reply.Channel != channel {
+			continue
+		}
+		if reply.Successful == nil || !*reply.Successful {
+			if reply.Error != "" {
+				return nil, fmt.Errorf("%s failed: %s", channel, reply.Error)
+			}
+			return nil, fmt.Errorf("%s failed", channel)
+		}
+		return &reply, nil
+	}
+	return nil, fmt.Errorf("no reply to %s", channel)
+}
+
+// realtimePayload returns the JSON of an event. The events are published as JSON encoded
+// in a string
+func realtimePayload(data []byte) ([]byte, error) {
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		// not a string
+		encoded = string(data)
+	}
+	if unquoted, err := strconv.Unquote(encoded); err == nil {
+		encoded = unquoted
+	}
+	var payload bytes.Buffer
+	if err := json.Compact(&payload, []byte(encoded)); err != nil {
+		return nil, fmt.Errorf("invalid event %q", encoded)
+	}
+	return payload.Bytes(), nil
+}
+
+func printRealtimeStatus(status string) {
+	fmt.Fprintln(os.Stderr, status)
+}
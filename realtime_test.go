@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeFaye publishes one message on each connect and forgets the clients after
+// every second connect, like a restarted server
+type fakeFaye struct {
+	lock       sync.Mutex
+	handshakes int
+	connects   int
+	clients    map[string]bool
+}
+
+func (f *fakeFaye) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var messages []bayeuxMessage
+	json.NewDecoder(r.Body).Decode(&messages)
+	message := messages[0]
+	ok := true
+	reply := bayeuxMessage{Channel: message.Channel, Successful: &ok, ClientId: message.ClientId}
+	replies := []bayeuxMessage{reply}
+
+	switch message.Channel {
+	case "/meta/handshake":
+		f.handshakes++
+		replies[0].ClientId = fmt.Sprintf("client%d", f.handshakes)
+		f.clients[replies[0].ClientId] = true
+	case "/meta/connect":
+		if !f.clients[message.ClientId] {
+			failed := false
+			replies[0].Successful = &failed
+			replies[0].Error = "401::Unknown client"
+			break
+		}
+		f.connects++
+		data, _ := json.Marshal(fmt.Sprintf(`{"severity":2,"message":"step %d","is_cap":true}`, f.connects))
+		replies = append(replies, bayeuxMessage{Channel: "/realtime/abc/deploy", Data: data})
+		if f.connects%2 == 0 {
+			f.clients = map[string]bool{}
+		}
+	}
+	json.NewEncoder(w).Encode(replies)
+}
+
+var _ = Describe("Realtime client", func() {
+	It("should handshake again when the server forgets the client", func() {
+		faye := &fakeFaye{clients: map[string]bool{}}
+		server := httptest.NewServer(faye)
+		defer server.Close()
+
+		realtime := newRealtimeClient(server.URL, "/realtime/abc/*")
+		// other specs mock the default transport
+		realtime.http = server.Client()
+		events := make(chan realtimeEvent)
+		stop := make(chan struct{})
+		defer close(stop)
+		go realtime.Listen(events, stop)
+
+		var messages []string
+		for len(messages) < 3 {
+			select {
+			case event := <-events:
+				payload, err := realtimePayload(event.Data)
+				Expect(err).NotTo(HaveOccurred())
+				var m logMessage
+				Expect(json.Unmarshal(payload, &m)).To(Succeed())
+				messages = append(messages, m.Message)
+			case <-time.After(5 * time.Second):
+				Fail("no message received")
+			}
+		}
+
+		Expect(messages).To(Equal([]string{"step 1", "step 2", "step 3"}))
+		faye.lock.Lock()
+		defer faye.lock.Unlock()
+		Expect(faye.handshakes).To(Equal(2))
+	})
+
+	It("should take severities by name or number", func() {
+		Expect(parseSeverity("warn")).To(Equal(3))
+		Expect(parseSeverity("ERROR")).To(Equal(4))
+		Expect(parseSeverity("5")).To(Equal(5))
+		_, err := parseSeverity("loud")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -6,8 +6,8 @@ import (
 	"log"
 	"net/url"
 	"os"
-	"runtime"
 
+	"github.com/cloud66-oss/cx/sshclient"
 	"github.com/cloud66/cli"
 )
 
@@ -56,11 +56,6 @@ $ cx register-server --org team --user ubuntu --file servers.txt --key ~/.ssh/pr
 }
 
 func runRegisterServer(c *cli.Context) {
-	if runtime.GOOS == "windows" {
-		printFatal("Not supported on Windows")
-		os.Exit(2)
-	}
-
 	org := mustOrg(c)
 	info, err := client.AccountInfo(org.Id, false)
 	if err != nil {
@@ -123,34 +118,14 @@ func registerServer(server string, script string, params url.Values, keyFile str
 	}
 
 	toRun := fmt.Sprintf("'curl %s -s %s?%s| bash -s'", extraHeader, script, params.Encode())
-	if keyFile == "" {
-		return startProgram("ssh", []string{
-			user + "@" + server,
-			"-o", "UserKnownHostsFile=/dev/null",
-			"-o", "CheckHostIP=no",
-			"-o", "StrictHostKeyChecking=no",
-			"-o", "LogLevel=QUIET",
-			"-o", "IdentitiesOnly=yes",
-			"-A",
-			"-t",
-			"-p", "22",
-			"sudo su - -c",
-			toRun,
-		})
-	} else {
-		return startProgram("ssh", []string{
-			user + "@" + server,
-			"-i", keyFile,
-			"-o", "UserKnownHostsFile=/dev/null",
-			"-o", "CheckHostIP=no",
-			"-o", "StrictHostKeyChecking=no",
-			"-o", "LogLevel=QUIET",
-			"-o", "IdentitiesOnly=yes",
-			"-A",
-			"-t",
-			"-p", "22",
-			"sudo su - -c",
-			toRun,
-		})
+	config := sshclient.Config{
+		Address: server,
+		User:    user,
+		KeyFile: keyFile,
+		// the server isn't part of a stack yet
+		HostKeyCallback: sshclient.KnownHostsCallback(knownHostsFile("registered")),
+		ForwardAgent:    true,
+		Logf:            sshDebugf,
 	}
+	return runRemoteCommand(config, "sudo su - -c "+toRun, true)
 }
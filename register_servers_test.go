@@ -34,7 +34,7 @@ var _ = Describe("Register a new server", func() {
 
 			// read stdout
 			output := StopCaptureStdout()
-			Expect(lastCommandExecuted.Args[17]).To(HavePrefix("'curl --header \"X-Force-Local-IP:true\" -s http://app.cloud66.com/xxx?| bash -s'"))
+			Expect(lastRemoteCommandExecuted).To(HavePrefix("sudo su - -c 'curl --header \"X-Force-Local-IP:true\" -s http://app.cloud66.com/xxx?| bash -s'"))
 			Expect(output[0]).To(HavePrefix("Register server(s) done."))
 		})
 
@@ -63,7 +63,7 @@ var _ = Describe("Register a new server", func() {
 
 			// read stdout
 			output := StopCaptureStdout()
-			Expect(lastCommandExecuted.Args[17]).To(HavePrefix("'curl  -s http://app.cloud66.com/xxx?| bash -s'"))
+			Expect(lastRemoteCommandExecuted).To(HavePrefix("sudo su - -c 'curl  -s http://app.cloud66.com/xxx?| bash -s'"))
 			Expect(output[0]).To(HavePrefix("Register server(s) done."))
 		})
 
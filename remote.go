@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66-oss/cx/sshclient"
+)
+
+// lastRemoteCommandExecuted is the last command sent to a server when running under test
+var lastRemoteCommandExecuted string
+
+// gatewayKeyFile is the key to the deploy gateway (bastion server), set from the global --gateway-key flag
+var gatewayKeyFile string
+
+// gatewayOpenTTL is how long a closed gateway is opened for when connecting through it
+const gatewayOpenTTL = 20 * time.Minute
+
+// serverSshConfig returns the ssh settings to connect to the server with the stack key,
+// jumping through its deploy gateway if it has one. The gateway should be opened first
+// with openServerGateways
+func serverSshConfig(server cloud66.Server, sshFile string) (sshclient.Config, error) {
+	config := sshclient.Config{
+		Address:         server.Address,
+		User:            server.UserName,
+		KeyFile:         sshFile,
+		HostKeyCallback: sshclient.KnownHostsCallback(knownHostsFile(server.StackUid)),
+		ForwardAgent:    true,
+		Logf:            sshDebugf,
+	}
+
+	if server.HasDeployGateway {
+		if err := checkServerGateway(server); err != nil {
+			return config, err
+		}
+		config.Gateway = &sshclient.Config{
+			Address:         server.DeployGatewayAddress,
+			User:            server.DeployGatewayUsername,
+			KeyFile:         gatewayKeyFile,
+			HostKeyCallback: config.HostKeyCallback,
+			Logf:            sshDebugf,
+		}
+	}
+
+	return config, nil
+}
+
+// checkServerGateway returns an error if cx doesn't know how to connect to the deploy gateway of the server
+func checkServerGateway(server cloud66.Server) error {
+	if len(server.DeployGatewayAddress) == 0 {
+		return errors.New("Can not find the address of gateway server")
+	}
+	if len(server.DeployGatewayUsername) == 0 {
+		return errors.New("Can not find the username of gateway server")
+	}
+	if gatewayKeyFile == "" {
+		return errors.New("This server deployed behind the gateway. You need to specify the key for the bastion server with --gateway-key")
+	}
+	return nil
+}
+
+// openServerGateways opens the closed deploy gateways of the servers. It should be called once
+// before connecting to the servers. Gateways that are not managed under any of the organizations
+// are used as they are
+func openServerGateways(servers []cloud66.Server) error {
+	addresses := map[string]bool{}
+	for _, server := range servers {
+		if !server.HasDeployGateway {
+			continue
+		}
+		if err := checkServerGateway(server); err != nil {
+			return err
+		}
+		addresses[server.DeployGatewayAddress] = true
+	}
+	if len(addresses) == 0 || underTest {
+		return nil
+	}
+
+	orgs, err := client.AccountInfos()
+	if err != nil {
+		return err
+	}
+	for _, org := range orgs {
+		gateways, err := client.ListGateways(org.Id)
+		if err != nil {
+			continue
+		}
+		for _, gateway := range gateways {
+			address := gateway.Address
+			if !addresses[address] {
+				address = gateway.PrivateIp
+			}
+			if !addresses[address] {
+				continue
+			}
+			delete(addresses, address)
+			if gatewayState(gateway) == "open" {
+				continue
+			}
+
+			fmt.Printf("Opening gateway %s for %s...\n", gateway.Name, gatewayOpenTTL)
+			if err := openGateway(org.Id, gateway.Id, gatewayKeyFile, int(gatewayOpenTTL.Seconds())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// knownHostsFile is where the host keys of the servers of a stack are kept. Keys are
+// added the first time cx connects to a server
+func knownHostsFile(stackUid string) string {
+	return filepath.Join(cxHome(), "known_hosts", stackUid)
+}
+
+// dialServer connects to the server and explains what to do if its host key has changed. The
+// connection is closed on Ctrl-C so the command using it returns
+func dialServer(config sshclient.Config) (*sshclient.Client, error) {
+	conn, err := sshclient.Dial(config)
+	if err != nil {
+		if sshclient.IsHostKeyChanged(err) {
+			printError("WARNING: REMOTE HOST IDENTIFICATION HAS CHANGED!")
+			printError("If the server has been rebuilt or its IP address reused, remove the old key with 'cx servers forget-host' and try again")
+		}
+		return nil, err
+	}
+
+	remove := onInterrupt(func() { conn.Close() })
+	go func() {
+		conn.Wait()
+		remove()
+	}()
+	return conn, nil
+}
+
+func sshDebugf(format string, args ...interface{}) {
+	if debugMode {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
+
+// runRemoteCommand runs the command on the server with the local console attached to it.
+// Interactive commands get a terminal (like ssh -t)
+func runRemoteCommand(config sshclient.Config, command string, interactive bool) error {
+	if debugMode {
+		fmt.Printf("Running Command %s on %s@%s\n", command, config.User, config.Address)
+	}
+
+	//TODO: ugly to mock the remote command when testing, need better solution
+	if underTest {
+		lastRemoteCommandExecuted = command
+		return nil
+	}
+
+	conn, err := dialServer(config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if interactive {
+		return conn.Shell(command, os.Stdin, os.Stdout, os.Stderr)
+	}
+	return conn.Run(command, os.Stdin, os.Stdout, os.Stderr)
+}
+
+// execRemoteCommand runs the command on the server without a terminal or any input
+func execRemoteCommand(config sshclient.Config, command string, stdout, stderr io.Writer) error {
+	if debugMode {
+		fmt.Printf("Running Command %s on %s@%s\n", command, config.User, config.Address)
+	}
+
+	if underTest {
+		lastRemoteCommandExecuted = command
+		return nil
+	}
+
+	conn, err := dialServer(config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Run(command, nil, stdout, stderr)
+}
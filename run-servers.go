@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66-oss/cx/sshclient"
+)
+
+type serverRunResult struct {
+	Server   cloud66.Server
+	ExitCode int
+	Duration time.Duration
+	Err      error
+}
+
+// findServersForRun returns all the servers with the given role and/or in the comma
+// separated list of server names or IPs
+func findServersForRun(servers []cloud66.Server, role string, names string) []cloud66.Server {
+	var result []cloud66.Server
+	seen := map[string]bool{}
+	add := func(server cloud66.Server) {
+		if !seen[server.Uid] {
+			seen[server.Uid] = true
+			result = append(result, server)
+		}
+	}
+
+	if role != "" {
+		for _, server := range servers {
+			for _, serverRole := range server.Roles {
+				if strings.EqualFold(serverRole, role) {
+					add(server)
+					break
+				}
+			}
+		}
+		if len(result) == 0 {
+			printFatal("No server found with role '%s'", role)
+		}
+	}
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		server, err := findServer(servers, name)
+		if err != nil {
+			printFatal(err.Error())
+		}
+		if server == nil {
+			printFatal("Server %s not found", name)
+		}
+		add(*server)
+	}
+
+	return result
+}
+
+// runServersCommand runs the command on all the servers, parallel at a time, with the
+// server name in front of each line of output. It exits with 1 if the command fails on any server
+func runServersCommand(servers []cloud66.Server, userCommand string, parallel int) {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	// the servers of a stack share the same key so fetch it once
+	sshFile, err := prepareLocalSshKey(servers[0])
+	must(err)
+	must(openServerGateways(servers))
+
+	width := 0
+	for _, server := range servers {
+		if len(server.Name) > width {
+			width = len(server.Name)
+		}
+	}
+
+	userCommand = fmt.Sprintf("source /var/.cloud66_env &>/dev/null ; %s", userCommand)
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallel)
+	results := make([]serverRunResult, len(servers))
+	for idx, server := range servers {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(idx int, server cloud66.Server) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			prefix := fmt.Sprintf("%-*s | ", width, server.Name)
+			stdout := newPrefixWriter(os.Stdout, prefix, &lock)
+			stderr := newPrefixWriter(os.Stderr, prefix, &lock)
+
+			started := time.Now()
+			err := runServerCommandWithOutput(server, sshFile, userCommand, stdout, stderr)
+			stdout.Flush()
+			stderr.Flush()
+
+			results[idx] = serverRunResult{
+				Server:   server,
+				ExitCode: sshclient.ExitStatus(err),
+				Duration: time.Since(started),
+				Err:      err,
+			}
+		}(idx, server)
+	}
+	wg.Wait()
+	closeServerLeases()
+
+	if printRunSummary(results) > 0 {
+		os.Exit(1)
+	}
+}
+
+func runServerCommandWithOutput(server cloud66.Server, sshFile string, userCommand string, stdout, stderr io.Writer) error {
+	// open the firewall. The leases are closed together once all the servers are done
+	if _, err := openServerLease(server, 2); err != nil {
+		return err
+	}
+
+	config, err := serverSshConfig(server, sshFile)
+	if err != nil {
+		return err
+	}
+	return execRemoteCommand(config, userCommand, stdout, stderr)
+}
+
+// printRunSummary prints the exit code of each server and returns the number of failed servers
+func printRunSummary(results []serverRunResult) int {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+
+	failed := 0
+	listRec(w, "SERVER", "ADDRESS", "EXIT CODE", "DURATION", "ERROR")
+	for _, result := range results {
+		exitCode := fmt.Sprintf("%d", result.ExitCode)
+		message := ""
+		if result.Err != nil {
+			failed++
+			if result.ExitCode < 0 {
+				// didn't get to run the command
+				exitCode = "-"
+				message = result.Err.Error()
+			}
+		}
+		listRec(w,
+			result.Server.Name,
+			result.Server.Address,
+			exitCode,
+			result.Duration.Round(time.Millisecond),
+			message,
+		)
+	}
+
+	w.Flush()
+
+	if failed > 0 {
+		printError("The command failed on %d of %d servers", failed, len(results))
+	}
+	return failed
+}
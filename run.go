@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"runtime"
 	"strings"
 	"time"
 
@@ -20,6 +19,19 @@ var cmdRun = &Command{
 			Name:  "server,svr",
 			Usage: "server on which to run the command [optional]",
 		},
+		cli.StringFlag{
+			Name:  "role",
+			Usage: "run the command on all the servers with this role [optional]",
+		},
+		cli.StringFlag{
+			Name:  "servers",
+			Usage: "comma separated list of servers on which to run the command [optional]",
+		},
+		cli.IntFlag{
+			Name:  "parallel",
+			Usage: "number of servers to run the command on at the same time with --role or --servers",
+			Value: 1,
+		},
 		cli.StringFlag{
 			Name:  "service,svc",
 			Usage: "name of the service in which to run the command [optional - docker/kubernetes stacks only]",
@@ -61,7 +73,13 @@ with this command.
 If a role is specified the command will connect to the first server with that role.
 Names are case insensitive and will work with the starting characters as well.
 
-This command is only supported on Linux and OS X (for Windows you can run this in a virtual machine if necessary)
+To run the command on more than one server, use --role to pick all the servers with a role and/or
+--servers with a comma separated list of servers. Each line of output is prefixed with the server name
+and a summary of the exit codes is shown at the end. cx exits with 1 if the command failed on any server.
+Use --parallel to run on more than one server at the same time.
+
+If the server is behind a deploy gateway (bastion server), provide the key to the gateway with --gateway-key.
+A closed gateway is opened for 20 minutes before connecting.
 
 Examples:
 $ cx run -s mystack --server lion 'ls -la' 
@@ -70,6 +88,12 @@ $ cx run -s mystack --server lion 'ls -la'
 $ cx run -s mystack --server lion -i 
 (runs "bash or sh" ON THE SERVER", and remains in the session)
 
+$ cx run -s mystack --role web --parallel 5 'sudo service nginx reload'
+(runs "sudo service nginx reload" ON ALL THE WEB SERVERS, 5 at a time)
+
+$ cx run -s mystack --servers lion,tiger 'uptime'
+(runs "uptime" ON BOTH SERVERS)
+
 $ cx run -s mystack --svc webapp 'ls -la'
 (runs "ls -la" IN A NEW CONTAINER OF THE SERVICE, returns the output, and exits)
 
@@ -85,16 +109,14 @@ $ cx run -s mystack --container web-123 -i 'bundle exec rails c'
 const ShellCommand = "/bin/sh -c 'if [ -e /bin/bash ]; then /bin/bash; else /bin/sh; fi'"
 
 func runRun(c *cli.Context) {
-	if runtime.GOOS == "windows" {
-		printFatal("Not supported on Windows")
-		os.Exit(2)
-	}
 	serviceName := c.String("service")
 	containerName := c.String("container")
 	serverName := c.String("server")
+	roleName := c.String("role")
+	serverNames := c.String("servers")
 	interactive := c.Bool("interactive")
-	if serverName == "" && containerName == "" && serviceName == "" {
-		printFatal("At least ONE of server/service/container must be specified")
+	if serverName == "" && containerName == "" && serviceName == "" && roleName == "" && serverNames == "" {
+		printFatal("At least ONE of server/role/servers/service/container must be specified")
 		os.Exit(2)
 	}
 
@@ -120,6 +142,20 @@ func runRun(c *cli.Context) {
 		printFatal(err.Error())
 	}
 
+	if roleName != "" || serverNames != "" {
+		if serverName != "" || serviceName != "" || containerName != "" {
+			printFatal("The role & servers options can not be used with server, service or container")
+		}
+		if interactive {
+			printFatal("Interactive sessions are not supported on multiple servers")
+		}
+		if userCommand == "" {
+			printFatal("A command is required to run on multiple servers")
+		}
+		runServersCommand(findServersForRun(servers, roleName, serverNames), userCommand, c.Int("parallel"))
+		return
+	}
+
 	var server *cloud66.Server
 	if serverName != "" {
 		server, err = findServer(servers, serverName)
@@ -159,7 +195,7 @@ func runRun(c *cli.Context) {
 			// we need a session
 			asyncResult, err := client.StartRemoteSession(stack.Uid, serviceName)
 			must(err)
-			genericRes, err := client.WaitStackAsyncAction(asyncResult.Id, stack.Uid, 5*time.Second, 4*time.Minute, false)
+			genericRes, err := waitAction(asyncResult.Id, stack.Uid, 5*time.Second, 4*time.Minute, false)
 			must(err)
 			if genericRes.Status != true {
 				printFatal("Unable to start session")
@@ -216,8 +252,9 @@ func runRun(c *cli.Context) {
 }
 
 func runServerCommand(server cloud66.Server, userCommand string, interactive bool) error {
-	// open lease, get address, get sshkey
-	sshFile, address := prepareForSSH(server)
+	// open lease, get sshkey
+	sshFile, lease := prepareForSSH(server)
+	defer lease.Close()
 
 	// default user command if it isn't specified
 	if userCommand == "" {
@@ -226,12 +263,13 @@ func runServerCommand(server cloud66.Server, userCommand string, interactive boo
 	userCommand = fmt.Sprintf("source /var/.cloud66_env &>/dev/null ; %s", userCommand)
 
 	// run the ssh
-	return runSSH(address, sshFile, userCommand, interactive)
+	return runSSH(server, sshFile, userCommand, interactive)
 }
 
 func runKubesCommand(server cloud66.Server, namespace string, podName string, userCommand string, interactive bool) error {
-	// open lease, get address, get sshkey
-	sshFile, address := prepareForSSH(server)
+	// open lease, get sshkey
+	sshFile, lease := prepareForSSH(server)
+	defer lease.Close()
 	// default the command if not supplied
 	if userCommand == "" {
 		userCommand = ShellCommand
@@ -243,50 +281,25 @@ func runKubesCommand(server cloud66.Server, namespace string, podName string, us
 		userCommand = fmt.Sprintf("kubectl --namespace %s exec %s -- %s", namespace, podName, userCommand)
 	}
 	// run the ssh
-	return runSSH(address, sshFile, userCommand, interactive)
+	return runSSH(server, sshFile, userCommand, interactive)
 }
 
-func prepareForSSH(server cloud66.Server) (string, string) {
-	address := fmt.Sprintf("%s@%s", server.UserName, server.Address)
-
+// prepareForSSH gets the ssh key and opens the firewall of the server. The lease is renewed
+// until it is closed
+func prepareForSSH(server cloud66.Server) (string, *serverLease) {
 	sshFile, err := prepareLocalSshKey(server)
 	must(err)
 	// open the firewall
-	var timeToOpen = 2
-	genericRes, err := client.LeaseSync(server.StackUid, nil, &timeToOpen, nil, &server.Uid)
-	must(err)
-	if genericRes.Status != true {
-		printFatal("Unable to open server lease")
-	}
-	return sshFile, address
+	return sshFile, mustOpenServerLease(server, 2)
 }
 
-func runSSH(address, sshFile, userCommand string, interactive bool) error {
-	if interactive {
-		return startProgram("ssh", []string{
-			address,
-			"-i", sshFile,
-			"-o", "UserKnownHostsFile=/dev/null",
-			"-o", "CheckHostIP=no",
-			"-o", "StrictHostKeyChecking=no",
-			"-o", "LogLevel=QUIET",
-			"-o", "IdentitiesOnly=yes",
-			"-A",
-			"-p", "22",
-			"-t",
-			userCommand,
-		})
+func runSSH(server cloud66.Server, sshFile, userCommand string, interactive bool) error {
+	if err := openServerGateways([]cloud66.Server{server}); err != nil {
+		return err
+	}
+	config, err := serverSshConfig(server, sshFile)
+	if err != nil {
+		return err
 	}
-	return startProgram("ssh", []string{
-		address,
-		"-i", sshFile,
-		"-o", "UserKnownHostsFile=/dev/null",
-		"-o", "CheckHostIP=no",
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "LogLevel=QUIET",
-		"-o", "IdentitiesOnly=yes",
-		"-A",
-		"-p", "22",
-		userCommand,
-	})
+	return runRemoteCommand(config, userCommand, interactive)
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cloud66-oss/cx/sshclient"
+
+	"github.com/cloud66/cli"
+)
+
+func runServerForgetHost(c *cli.Context) {
+	stack := mustStack(c)
+
+	if len(c.Args()) != 0 {
+		cli.ShowSubcommandHelp(c)
+		os.Exit(2)
+	}
+
+	file := knownHostsFile(stack.Uid)
+	if c.Bool("all") {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			printFatal(err.Error())
+		}
+		fmt.Printf("Removed all known host keys of %s\n", stack.Name)
+		return
+	}
+
+	serverName := c.String("server")
+	if serverName == "" {
+		printFatal("No server specified. Use --server or --all")
+	}
+
+	// the server might have been deleted already so we can also forget a plain address
+	host := serverName
+	servers, err := client.Servers(stack.Uid)
+	if err != nil {
+		printFatal(err.Error())
+	}
+	server, err := findServer(servers, serverName)
+	if err != nil {
+		printFatal(err.Error())
+	}
+	if server != nil {
+		host = server.Address
+		fmt.Printf("Server: %s\n", server.Name)
+	}
+
+	removed, err := sshclient.ForgetHost(file, host)
+	if err != nil {
+		printFatal(err.Error())
+	}
+	if removed == 0 {
+		printFatal("No known host key for %s", host)
+	}
+	fmt.Printf("Removed the host key of %s. The new key will be trusted on the next connection\n", host)
+}
@@ -50,6 +50,9 @@ func executeServerReboot(stack cloud66.Stack, server cloud66.Server) {
 		printFatal(err.Error())
 	}
 	genericRes, err := endServerReboot(*asyncId, stack.Uid)
+	if actionNotWaited(err, *asyncId) {
+		return
+	}
 	if err != nil {
 		printFatal(err.Error())
 	}
@@ -66,5 +69,5 @@ func startServerReboot(stackUid string, serverUid string) (*int, error) {
 }
 
 func endServerReboot(asyncId int, stackUid string) (*cloud66.GenericResponse, error) {
-	return client.WaitStackAsyncAction(asyncId, stackUid, 10*time.Second, 30*time.Minute, true)
+	return waitStackAction(asyncId, stackUid, 10*time.Second, 30*time.Minute, true)
 }
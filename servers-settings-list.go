@@ -35,7 +35,9 @@ func runServerSettings(c *cli.Context) {
 		printFatal("Server '" + serverName + "' not found")
 	}
 
-	fmt.Printf("Server: %s\n", server.Name)
+	if !structuredOutput() {
+		fmt.Printf("Server: %s\n", server.Name)
+	}
 
 	getServerSettings(*stack, *server, c.Args())
 }
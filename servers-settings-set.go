@@ -69,6 +69,9 @@ func executeServerSet(stack cloud66.Stack, server cloud66.Server, c *cli.Context
 				printFatal(err.Error())
 			}
 			genericRes, err := endServerSet(*asyncId, stack.Uid)
+			if actionNotWaited(err, *asyncId) {
+				return
+			}
 			if err != nil {
 				printFatal(err.Error())
 			}
@@ -90,5 +93,5 @@ func startServerSet(stackUid string, serverUid string, key string, value string)
 }
 
 func endServerSet(asyncId int, stackUid string) (*cloud66.GenericResponse, error) {
-	return client.WaitStackAsyncAction(asyncId, stackUid, 5*time.Second, 20*time.Minute, true)
+	return waitStackAction(asyncId, stackUid, 5*time.Second, 20*time.Minute, true)
 }
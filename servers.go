@@ -55,6 +55,35 @@ orca         162.243.201.164  [rails web app]  Healthy   Mar 26 11:23
 
 Examples:
 $ cx server reboot -s mystack --server lion
+`,
+		},
+		cli.Command{
+			Name:   "forget-host",
+			Action: runServerForgetHost,
+			Usage:  "removes the known host key of a server",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "server",
+					Usage: "server name or IP address",
+				},
+				cli.BoolFlag{
+					Name:  "all",
+					Usage: "remove the host keys of all the servers of the stack",
+				},
+			},
+			Description: `Removes the known host key of a server.
+
+cx remembers the host key of each server the first time it connects to it and refuses to connect
+if the key changes later, as this could mean someone is intercepting the connection.
+If the server has been rebuilt or its IP address has been reused, use this command to forget the
+old key. The new key will be trusted on the next connection.
+
+The keys are kept in ~/.cloud66/known_hosts with one file per stack.
+
+Examples:
+$ cx servers forget-host -s mystack --server lion
+$ cx servers forget-host -s mystack --server 52.65.34.98
+$ cx servers forget-host -s mystack --all
 `,
 		},
 		cli.Command{
@@ -156,6 +185,9 @@ func runServers(c *cli.Context) {
 
 func printServerList(w io.Writer, servers []cloud66.Server) {
 	sort.Sort(serversByName(servers))
+	if printStructured(servers) {
+		return
+	}
 	for _, a := range servers {
 		if a.Name != "" {
 			listServer(w, a)
@@ -44,7 +44,9 @@ func runServiceInfo(c *cli.Context) {
 		if !server.HasRole("docker") && !server.HasRole("kubes") {
 			printFatal("Server '" + flagServer + "' is not a docker server")
 		}
-		fmt.Printf("Server: %s\n", server.Name)
+		if !structuredOutput() {
+			fmt.Printf("Server: %s\n", server.Name)
+		}
 		serverUid = &server.Uid
 	}
 
@@ -56,6 +58,10 @@ func runServiceInfo(c *cli.Context) {
 }
 
 func printServiceInfoList(w io.Writer, service *cloud66.Service) {
+	if printStructured(service) {
+		return
+	}
+
 	listRec(w, "NAME", "VALUE")
 	listRec(w, "name", service.Name)
 	listRec(w, "source type", service.SourceType)
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66/cli"
+)
+
+func runServiceLogs(c *cli.Context) {
+	if len(c.Args()) != 1 {
+		cli.ShowSubcommandHelp(c)
+		os.Exit(2)
+	}
+
+	stack := mustStack(c)
+
+	var serverUID *string
+	flagServer := c.String("server")
+	if flagServer != "" {
+		server := mustServer(c, *stack, flagServer, false)
+		serverUID = &server.Uid
+	}
+
+	serviceName := c.Args()[0]
+	result, err := client.GetContainers(stack.Uid, serverUID, &serviceName)
+	must(err)
+
+	containers := serviceLogContainers(result)
+	if len(containers) == 0 {
+		printFatal("No containers found for service '%s'", serviceName)
+	}
+
+	if containerLogs(*stack, containers, containerLogOptionsFrom(c)) > 0 {
+		os.Exit(1)
+	}
+}
+
+// serviceLogContainers returns the containers of the service, leaving out the entries without a container Id
+func serviceLogContainers(containers []cloud66.Container) []cloud66.Container {
+	var result []cloud66.Container
+	for _, container := range containers {
+		if container.Uid != "" {
+			result = append(result, container)
+		}
+	}
+	return result
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/cloud66-oss/cloud66"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Service logs", func() {
+	It("should leave out the containers without an Id", func() {
+		containers := serviceLogContainers([]cloud66.Container{
+			{Uid: "a1", Name: "web-1"},
+			{Name: "web-2"},
+			{Uid: "a3", Name: "web-3"},
+		})
+		Expect(containers).To(HaveLen(2))
+		Expect(containers[0].Uid).To(Equal("a1"))
+		Expect(containers[1].Uid).To(Equal("a3"))
+
+		Expect(serviceLogContainers(nil)).To(BeEmpty())
+	})
+})
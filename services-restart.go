@@ -35,6 +35,9 @@ func runServiceRestart(c *cli.Context) {
 		printFatal(err.Error())
 	}
 	genericRes, err := endServiceAction(*asyncId, stack.Uid)
+	if actionNotWaited(err, *asyncId) {
+		return
+	}
 	if err != nil {
 		printFatal(err.Error())
 	}
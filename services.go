@@ -129,6 +129,25 @@ Examples:
 $ cx services restart -s mystack my_web_service
 $ cx services restart -s mystack a_backend_service
 $ cx services restart -s mystack --server my_server my_web_service
+`},
+		cli.Command{
+			Name:   "logs",
+			Action: runServiceLogs,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name: "server",
+				},
+			}, containerLogFlags...),
+			Usage: "shows the logs of all the containers of the given service",
+			Description: `Shows the output (stdout and stderr) of all the containers of the given service, with the
+container name in front of each line.
+The list of available stack services can be obtained through the 'services' command.
+If the server is provided it will only show the containers on the specified server.
+
+Examples:
+$ cx services logs -s mystack my_web_service
+$ cx services logs -s mystack --tail 20 --follow my_web_service
+$ cx services logs -s mystack --server my_server --since 1h my_web_service
 `},
 		cli.Command{
 			Name:   "info",
@@ -178,7 +197,9 @@ func runServices(c *cli.Context) {
 		if !server.HasRole("docker") && !server.HasRole("kubes") {
 			printFatal("Server '" + flagServer + "' can not host containers")
 		}
-		fmt.Printf("Server: %s\n", server.Name)
+		if !structuredOutput() {
+			fmt.Printf("Server: %s\n", server.Name)
+		}
 		serverUid = &server.Uid
 	}
 
@@ -204,13 +225,17 @@ func runServices(c *cli.Context) {
 }
 
 func printServicesList(w io.Writer, services []cloud66.Service, flagServer string) {
+	sort.Sort(ServiceByNameServer(services))
+	if printStructured(services) {
+		return
+	}
+
 	listRec(w,
 		"SERVICE NAME",
 		"SERVER",
 		"COUNT",
 	)
 
-	sort.Sort(ServiceByNameServer(services))
 	for _, a := range services {
 		listService(w, a, flagServer)
 	}
@@ -244,7 +269,7 @@ func startServiceAction(stackUid string, serviceName *string, serverUid *string,
 }
 
 func endServiceAction(asyncId int, stackUid string) (*cloud66.GenericResponse, error) {
-	return client.WaitStackAsyncAction(asyncId, stackUid, 5*time.Second, 10*time.Minute, true)
+	return waitStackAction(asyncId, stackUid, 5*time.Second, 10*time.Minute, true)
 }
 
 type ServiceByNameServer []cloud66.Service
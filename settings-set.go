@@ -34,6 +34,9 @@ func runSet(c *cli.Context) {
 				printFatal(err.Error())
 			}
 			genericRes, err := endSet(*asyncId, stack.Uid)
+			if actionNotWaited(err, *asyncId) {
+				return
+			}
 			if err != nil {
 				printFatal(err.Error())
 			}
@@ -55,5 +58,5 @@ func startSet(stackUid string, key string, value string) (*int, error) {
 }
 
 func endSet(asyncId int, stackUid string) (*cloud66.GenericResponse, error) {
-	return client.WaitStackAsyncAction(asyncId, stackUid, 5*time.Second, 20*time.Minute, true)
+	return waitStackAction(asyncId, stackUid, 5*time.Second, 20*time.Minute, true)
 }
@@ -90,6 +90,9 @@ func runSettings(c *cli.Context) {
 
 func printSettingList(w io.Writer, settings []cloud66.StackSetting) {
 	sort.Sort(settingsByName(settings))
+	if printStructured(settings) {
+		return
+	}
 	for _, a := range settings {
 		if a.Key != "" {
 			listSetting(w, a)
@@ -201,6 +201,9 @@ func generateYamlComment(filename string, snapshot string, formation string, seq
 
 func printSnapshotList(w io.Writer, snapshots []cloud66.Snapshot) {
 	sort.Sort(snapshotsByDate(snapshots))
+	if printStructured(snapshots) {
+		return
+	}
 	listRec(w,
 		"UID",
 		"LAST ACTION AT",
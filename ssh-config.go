@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66/cli"
+)
+
+var cmdSshConfig = &Command{
+	Name:  "ssh-config",
+	Build: buildBasicCommand,
+	Run:   runSshConfig,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "write,w",
+			Usage: "write the configuration to ~/.ssh/cx/<stack>.conf instead of showing it",
+		},
+		cli.BoolFlag{
+			Name:  "include",
+			Usage: "add an Include for the files written with --write to ~/.ssh/config if it doesn't have one",
+		},
+		cli.StringFlag{
+			Name:  "suffix",
+			Usage: "suffix of the host names (ie. lion.<suffix>). Defaults to the stack name",
+		},
+	},
+	NeedsStack: true,
+	NeedsOrg:   false,
+	Short:      "generates an OpenSSH configuration for the servers of a stack",
+	Long: `This command generates a Host entry for each server of the stack so ssh, scp, IDE remote plugins,
+Ansible and other tools that use the OpenSSH configuration can connect to the servers without cx.
+
+Each entry uses the server name and the stack name as the host name (ie. lion.mystack) and has the user,
+address and SSH key of the server. The keys are downloaded to ~/.ssh like the other cx commands do.
+Servers behind a deploy gateway (bastion server) jump through it with ProxyJump. Use the global
+--gateway-key option to set the key to the gateway.
+
+By default the configuration is shown. Use --write to save it to ~/.ssh/cx/<stack>.conf, and --include
+to add 'Include cx/*.conf' to ~/.ssh/config so ssh picks up the files of all the stacks.
+
+The firewall of the servers is not opened by this command. Use 'cx lease' to open it first.
+
+Examples:
+$ cx ssh-config -s mystack
+$ cx ssh-config -s mystack --write --include
+$ cx lease -s mystack && ssh lion.mystack
+`,
+}
+
+// sshConfigDir is where the files written by ssh-config are kept, relative to ~/.ssh
+const sshConfigDir = "cx"
+
+var sshHostUnsafe = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+func runSshConfig(c *cli.Context) {
+	stack := mustStack(c)
+
+	servers, err := client.Servers(stack.Uid)
+	if err != nil {
+		printFatal(err.Error())
+	}
+	if len(servers) == 0 {
+		printFatal("No servers found on %s", stack.Name)
+	}
+
+	keyFiles := map[string]string{}
+	warned := false
+	for _, server := range servers {
+		if server.HasDeployGateway && gatewayKeyFile == "" && !warned {
+			printError("Some servers are behind a deploy gateway. Use --gateway-key to add the key of the gateway, or ssh will use your default keys for it")
+			warned = true
+		}
+		sshFile, err := prepareLocalSshKey(server)
+		must(err)
+		keyFiles[server.Uid] = sshFile
+	}
+
+	suffix := c.String("suffix")
+	if suffix == "" {
+		suffix = stack.Name
+	}
+	suffix = sshHostName(suffix)
+
+	if !c.Bool("write") {
+		must(writeSshConfig(os.Stdout, *stack, servers, keyFiles, suffix))
+		return
+	}
+
+	var buf bytes.Buffer
+	must(writeSshConfig(&buf, *stack, servers, keyFiles, suffix))
+
+	dir := filepath.Join(homePath(), ".ssh", sshConfigDir)
+	must(os.MkdirAll(dir, 0700))
+	file := filepath.Join(dir, suffix+".conf")
+	must(ioutil.WriteFile(file, buf.Bytes(), 0600))
+	fmt.Printf("Wrote %d hosts to %s\n", len(servers), file)
+
+	if c.Bool("include") {
+		added, err := addSshConfigInclude(filepath.Join(homePath(), ".ssh", "config"))
+		must(err)
+		if added {
+			fmt.Println("Added the cx hosts to ~/.ssh/config")
+		}
+	} else {
+		fmt.Printf("Add 'Include %s/*.conf' to the top of ~/.ssh/config (or use --include) to use them\n", sshConfigDir)
+	}
+}
+
+// writeSshConfig writes a Host entry for each server, and one for each of their gateways
+func writeSshConfig(w io.Writer, stack cloud66.Stack, servers []cloud66.Server, keyFiles map[string]string, suffix string) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Servers of %s (%s), generated by cx ssh-config\n", stack.Name, stack.Environment)
+
+	knownHosts := knownHostsFile(stack.Uid)
+	gateways := map[string]string{}
+	for _, server := range servers {
+		if !server.HasDeployGateway || gateways[server.DeployGatewayAddress] != "" {
+			continue
+		}
+		alias := "gateway." + suffix
+		if len(gateways) > 0 {
+			alias = fmt.Sprintf("gateway%d.%s", len(gateways)+1, suffix)
+		}
+		gateways[server.DeployGatewayAddress] = alias
+
+		fmt.Fprintf(&buf, "\nHost %s\n", alias)
+		writeSshOption(&buf, "HostName", server.DeployGatewayAddress)
+		writeSshOption(&buf, "User", server.DeployGatewayUsername)
+		if gatewayKeyFile != "" {
+			writeSshOption(&buf, "IdentityFile", gatewayKeyFile)
+			writeSshOption(&buf, "IdentitiesOnly", "yes")
+		}
+		writeSshOption(&buf, "UserKnownHostsFile", knownHosts)
+	}
+
+	for _, server := range servers {
+		fmt.Fprintf(&buf, "\nHost %s\n", sshHostName(server.Name)+"."+suffix)
+		writeSshOption(&buf, "HostName", server.Address)
+		writeSshOption(&buf, "User", server.UserName)
+		writeSshOption(&buf, "IdentityFile", keyFiles[server.Uid])
+		writeSshOption(&buf, "IdentitiesOnly", "yes")
+		writeSshOption(&buf, "UserKnownHostsFile", knownHosts)
+		writeSshOption(&buf, "ForwardAgent", "yes")
+		if server.HasDeployGateway {
+			writeSshOption(&buf, "ProxyJump", gateways[server.DeployGatewayAddress])
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeSshOption(w io.Writer, name, value string) {
+	if value == "" {
+		return
+	}
+	if strings.ContainsAny(value, " \t") {
+		value = `"` + value + `"`
+	}
+	fmt.Fprintf(w, "  %s %s\n", name, value)
+}
+
+// sshHostName turns a server or stack name into something that can be used as a host name
+func sshHostName(name string) string {
+	return strings.Trim(sshHostUnsafe.ReplaceAllString(strings.ToLower(name), "-"), "-.")
+}
+
+// addSshConfigInclude adds an Include for the ssh-config files to the top of the ssh
+// configuration, as an Include after a Host entry would only apply to that host
+func addSshConfigInclude(file string) (bool, error) {
+	include := fmt.Sprintf("Include %s/*.conf", sshConfigDir)
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(line) == include {
+			return false, nil
+		}
+	}
+
+	content = append([]byte(include+"\n\n"), content...)
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return false, err
+	}
+	return true, ioutil.WriteFile(file, content, 0600)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloud66-oss/cloud66"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SSH config", func() {
+	stack := cloud66.Stack{Uid: "abc", Name: "My Stack", Environment: "production"}
+	servers := []cloud66.Server{
+		{Uid: "1", Name: "Lion", Address: "52.65.34.98", UserName: "ubuntu"},
+		{Uid: "2", Name: "tiger", Address: "10.0.0.5", UserName: "ubuntu", HasDeployGateway: true, DeployGatewayAddress: "52.65.34.1", DeployGatewayUsername: "ec2-user"},
+	}
+	keyFiles := map[string]string{"1": "/home/me/.ssh/cx_abc", "2": "/home/me/.ssh/cx_abc"}
+
+	It("should write a host entry for each server and gateway", func() {
+		gatewayKeyFile = "/home/me/.ssh/bastion.pem"
+		defer func() { gatewayKeyFile = "" }()
+
+		var buf bytes.Buffer
+		Expect(writeSshConfig(&buf, stack, servers, keyFiles, sshHostName(stack.Name))).To(Succeed())
+		config := buf.String()
+
+		Expect(config).To(ContainSubstring("Host lion.my-stack\n  HostName 52.65.34.98\n  User ubuntu\n  IdentityFile /home/me/.ssh/cx_abc\n"))
+		Expect(config).To(ContainSubstring("Host gateway.my-stack\n  HostName 52.65.34.1\n  User ec2-user\n  IdentityFile /home/me/.ssh/bastion.pem\n"))
+		Expect(config).To(ContainSubstring("Host tiger.my-stack\n  HostName 10.0.0.5\n"))
+		Expect(config).To(ContainSubstring("  ProxyJump gateway.my-stack\n"))
+		Expect(config).To(ContainSubstring("  UserKnownHostsFile " + knownHostsFile("abc") + "\n"))
+	})
+
+	It("should add the include to the top of the ssh config once", func() {
+		dir, err := ioutil.TempDir("", "ssh-config")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		file := filepath.Join(dir, "config")
+		Expect(ioutil.WriteFile(file, []byte("Host *\n  ServerAliveInterval 60\n"), 0600)).To(Succeed())
+
+		added, err := addSshConfigInclude(file)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(added).To(BeTrue())
+		added, err = addSshConfigInclude(file)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(added).To(BeFalse())
+
+		content, err := ioutil.ReadFile(file)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("Include cx/*.conf\n\nHost *\n  ServerAliveInterval 60\n"))
+	})
+})
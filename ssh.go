@@ -3,8 +3,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"runtime"
-	"strings"
 
 	"github.com/cloud66/cli"
 	"github.com/cloud66-oss/cloud66"
@@ -49,8 +47,6 @@ Names are case insensitive and will work with the starting characters as well.
 
 You should provide a key to your bastion server if it is deployed with a deploy gateway.
 
-This command is only supported on Linux and OS X.
-
 Examples:
 $ cx ssh -s mystack lion
 $ cx ssh -s mystack 52.65.34.98
@@ -60,11 +56,6 @@ $ cx ssh --gateway-key ~/.ssh/bastion_key  -s mystack db
 }
 
 func runSsh(c *cli.Context) {
-	if runtime.GOOS == "windows" {
-		printFatal("Not supported on Windows")
-		os.Exit(2)
-	}
-
 	stack := mustStack(c)
 
 	if len(c.Args()) != 1 {
@@ -89,20 +80,8 @@ func runSsh(c *cli.Context) {
 		printFatal("Server '" + serverName + "' not found")
 	}
 
-	flagGatewayKey := ""
-	if server.HasDeployGateway {
-		if c.IsSet("gateway-key") {
-			flagGatewayKey = c.String("gateway-key")
-			if len(server.DeployGatewayAddress) == 0 {
-				printFatal("Can not find the address of gateway server")
-			}
-			if len(server.DeployGatewayUsername) == 0 {
-				printFatal("Can not find the username of gateway server")
-			}
-		} else {
-			cli.ShowCommandHelp(c, "ssh")
-			printFatal("This server deployed behind the gateway. You need to specify the key for the bastion server")
-		}
+	if c.IsSet("gateway-key") {
+		gatewayKeyFile = expandPath(c.String("gateway-key"))
 	}
 
 	verbosity := 0
@@ -116,67 +95,39 @@ func runSsh(c *cli.Context) {
 
 	fmt.Printf("Server: %s\n", server.Name)
 
-	err = sshToServer(*server, flagGatewayKey, verbosity)
+	err = sshToServer(*server, verbosity)
 	if err != nil {
 		printError("If you're having issues connecting to your server, you may find some help at https://help.cloud66.com/maestro/how-to-guides/deployment/ssh-to-server.html")
 		printFatal(err.Error())
 	}
 }
 
-func sshToServer(server cloud66.Server, gatewayKey string, verbosity int) error {
+func sshToServer(server cloud66.Server, verbosity int) error {
 	sshFile, err := prepareLocalSshKey(server)
 	must(err)
 
 	// open the firewall
-	timeToOpen := 20
 	fmt.Printf("Opening access to %s...\n", server.Address)
-	genericRes, err := client.LeaseSync(server.StackUid, nil, &timeToOpen, nil, &server.Uid)
-	must(err)
-	if genericRes.Status != true {
-		printFatal("Unable to open server lease")
-	}
+	lease := mustOpenServerLease(server, 20)
+	defer lease.Close()
 
 	fmt.Printf("Connecting to %s (%s)...\n", server.Name, server.Address)
 
-	vflag := ""
-	if verbosity == 1 {
-		vflag = "-v"
-	} else if verbosity == 2 {
-		vflag = "-vv"
-	} else if verbosity == 3 {
-		vflag = "-vvv"
+	if err := openServerGateways([]cloud66.Server{server}); err != nil {
+		return err
 	}
-
-	if server.HasDeployGateway {
-		tags := []string{
-			"ssh",
-			"-o", "ProxyCommand='ssh " + server.DeployGatewayUsername + "@" + server.DeployGatewayAddress + " -i  " + gatewayKey + "  nc  " + server.Address + " 22' ",
-			"-o", "UserKnownHostsFile=/dev/null",
-			"-o", "CheckHostIP=no",
-			"-o", "StrictHostKeyChecking=no",
-			"-o", "LogLevel=QUIET",
-			"-o", "IdentitiesOnly=yes",
-			"-A",
-			"-p", "22",
-			vflag,
-			server.UserName + "@" + server.Address,
-			"-i", sshFile,
+	config, err := serverSshConfig(server, sshFile)
+	if err != nil {
+		return err
+	}
+	if verbosity > 0 {
+		config.Logf = func(format string, args ...interface{}) {
+			fmt.Fprintf(os.Stderr, format+"\n", args...)
+		}
+		if config.Gateway != nil {
+			config.Gateway.Logf = config.Logf
 		}
-		return startProgram("bash", []string{
-			"-c", strings.Join(tags, " "),
-		})
-	} else {
-		return startProgram("ssh", []string{
-			server.UserName + "@" + server.Address,
-			"-i", sshFile,
-			"-o", "UserKnownHostsFile=/dev/null",
-			"-o", "CheckHostIP=no",
-			"-o", "StrictHostKeyChecking=no",
-			"-o", "LogLevel=QUIET",
-			"-o", "IdentitiesOnly=yes",
-			"-A",
-			"-p", "22",
-			vflag,
-		})
 	}
+
+	return runRemoteCommand(config, "", true)
 }
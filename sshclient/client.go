@@ -0,0 +1,229 @@
+// Package sshclient is the SSH layer shared by the cx commands that talk to servers
+// (ssh, run, tunnel, tail, upload, download and register-server). It replaces calling
+// the ssh and scp binaries so these commands work the same way on every platform.
+package sshclient
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// DefaultPort is used when the address doesn't have a port
+const DefaultPort = 22
+
+// DefaultTimeout is used when Config.Timeout is not set
+const DefaultTimeout = 30 * time.Second
+
+// Config describes how to connect to a server
+type Config struct {
+	// Address is the host name or IP of the server, with an optional port
+	Address string
+	// User is the login user on the server
+	User string
+	// KeyFile is the private key used to log in. When empty, the ssh agent and the
+	// default keys under ~/.ssh are used instead
+	KeyFile string
+	// Gateway is the bastion server the connection should jump through (like ProxyJump)
+	Gateway *Config
+	// HostKeyCallback verifies the host key of the server. It is required
+	HostKeyCallback ssh.HostKeyCallback
+	// ForwardAgent forwards the local ssh agent to the server (like ssh -A)
+	ForwardAgent bool
+	// Timeout is the time allowed to establish the TCP connection
+	Timeout time.Duration
+	// Logf is used to print debug information about the connection. Can be nil
+	Logf func(format string, args ...interface{})
+}
+
+// Client is a connection to a server, possibly through a gateway
+type Client struct {
+	*ssh.Client
+
+	config  Config
+	gateway *Client
+	agent   net.Conn
+}
+
+// Dial connects and logs in to the server described by config
+func Dial(config Config) (*Client, error) {
+	c := &Client{config: config}
+
+	if config.Gateway != nil {
+		gateway, err := Dial(*config.Gateway)
+		if err != nil {
+			return nil, fmt.Errorf("unable to connect to the gateway %s: %w", config.Gateway.Address, err)
+		}
+		c.gateway = gateway
+	}
+
+	if err := c.connect(); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Client) connect() error {
+	clientConfig, err := c.clientConfig()
+	if err != nil {
+		return err
+	}
+
+	address := HostPort(c.config.Address)
+	var conn net.Conn
+	if c.gateway != nil {
+		c.logf("Connecting to %s through %s", address, c.config.Gateway.Address)
+		conn, err = c.gateway.Dial("tcp", address)
+	} else {
+		c.logf("Connecting to %s", address)
+		conn, err = net.DialTimeout("tcp", address, clientConfig.Timeout)
+	}
+	if err != nil {
+		return err
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, address, clientConfig)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	c.Client = ssh.NewClient(sshConn, chans, reqs)
+	c.logf("Logged in to %s as %s (%s)", address, c.config.User, sshConn.ServerVersion())
+
+	if c.config.ForwardAgent && c.agent != nil {
+		if err := agent.ForwardToAgent(c.Client, agent.NewClient(c.agent)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) clientConfig() (*ssh.ClientConfig, error) {
+	var signers []ssh.Signer
+
+	if c.config.KeyFile != "" {
+		signer, err := readKey(c.config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the key %s: %s", c.config.KeyFile, err)
+		}
+		signers = append(signers, signer)
+	} else {
+		for _, keyFile := range defaultKeyFiles() {
+			// keys with a passphrase are left to the agent
+			if signer, err := readKey(keyFile); err == nil {
+				c.logf("Using key %s", keyFile)
+				signers = append(signers, signer)
+			}
+		}
+	}
+
+	if c.config.KeyFile == "" || c.config.ForwardAgent {
+		if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+			if conn, err := net.Dial("unix", socket); err == nil {
+				c.agent = conn
+			} else {
+				c.logf("Unable to connect to the ssh agent: %s", err)
+			}
+		}
+	}
+
+	auth := []ssh.AuthMethod{ssh.PublicKeys(signers...)}
+	if c.config.KeyFile == "" && c.agent != nil {
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(c.agent).Signers))
+	}
+	if len(signers) == 0 && c.agent == nil {
+		return nil, errors.New("no ssh key found")
+	}
+
+	if c.config.HostKeyCallback == nil {
+		return nil, errors.New("no host key callback given to check the server")
+	}
+
+	timeout := c.config.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &ssh.ClientConfig{
+		User:            c.config.User,
+		Auth:            auth,
+		HostKeyCallback: c.config.HostKeyCallback,
+		Timeout:         timeout,
+	}, nil
+}
+
+// Close closes the connection to the server and the gateway
+func (c *Client) Close() error {
+	var err error
+	if c.Client != nil {
+		err = c.Client.Close()
+	}
+	if c.agent != nil {
+		c.agent.Close()
+	}
+	if c.gateway != nil {
+		c.gateway.Close()
+	}
+	return err
+}
+
+func (c *Client) logf(format string, args ...interface{}) {
+	if c.config.Logf != nil {
+		c.config.Logf(format, args...)
+	}
+}
+
+// HostPort adds the default ssh port to the address if it doesn't have one
+func HostPort(address string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(address, strconv.Itoa(DefaultPort))
+}
+
+// ExitStatus returns the exit status of the remote command from the error returned
+// by Run or Exec. It returns 0 for no error and -1 if the command didn't exit normally
+func ExitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*ssh.ExitError); ok {
+		return exitErr.ExitStatus()
+	}
+	return -1
+}
+
+func readKey(keyFile string) (ssh.Signer, error) {
+	buf, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKey(buf)
+}
+
+func defaultKeyFiles() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+
+	var result []string
+	for _, name := range []string{"id_rsa", "id_ecdsa", "id_ed25519"} {
+		keyFile := filepath.Join(home, ".ssh", name)
+		if _, err := os.Stat(keyFile); err == nil {
+			result = append(result, keyFile)
+		}
+	}
+	return result
+}
@@ -0,0 +1,96 @@
+package sshclient
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrConnectionLost is returned by Forward when the connection to the server drops
+var ErrConnectionLost = errors.New("connection to the server lost")
+
+type deadlineListener interface {
+	SetDeadline(t time.Time) error
+}
+
+// Forward accepts connections on the local listener and forwards them to remoteAddress,
+// as seen from the server (like ssh -L). It returns when the listener is closed or when
+// the connection to the server is lost, in which case the listener is left open so the
+// caller can reconnect and carry on forwarding with it
+func (c *Client) Forward(listener net.Listener, remoteAddress string) error {
+	return c.serve(listener, func(conn net.Conn) {
+		if err := c.ForwardConn(conn, remoteAddress); err != nil {
+			c.logf("Unable to forward to %s: %s", remoteAddress, err)
+		}
+	})
+}
+
+// serve accepts the connections on the listener and hands each one to handle until the
+// listener is closed or the connection to the server is lost
+func (c *Client) serve(listener net.Listener, handle func(conn net.Conn)) error {
+	lost := make(chan struct{})
+	go func() {
+		c.Wait()
+		close(lost)
+	}()
+
+	for {
+		if l, ok := listener.(deadlineListener); ok {
+			l.SetDeadline(time.Now().Add(time.Second))
+		}
+		conn, err := listener.Accept()
+
+		select {
+		case <-lost:
+			if conn != nil {
+				conn.Close()
+			}
+			return ErrConnectionLost
+		default:
+		}
+
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+
+		go handle(conn)
+	}
+}
+
+// ForwardConn connects to remoteAddress from the server and copies the data between it
+// and the local connection until either side is closed
+func (c *Client) ForwardConn(local net.Conn, remoteAddress string) error {
+	defer local.Close()
+
+	remote, err := c.Dial("tcp", remoteAddress)
+	if err != nil {
+		return err
+	}
+	defer remote.Close()
+
+	pipe(local, remote)
+	return nil
+}
+
+func pipe(a, b net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	copyAndClose := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		// let the other side know nothing more is coming
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+	go copyAndClose(a, b)
+	go copyAndClose(b, a)
+	wg.Wait()
+}
@@ -0,0 +1,141 @@
+package sshclient
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyChangedError is returned when a server presents a different key from the one
+// recorded in the known hosts file. This can mean someone is intercepting the connection
+type HostKeyChangedError struct {
+	Host        string
+	File        string
+	Fingerprint string
+	Known       []string
+}
+
+func (e *HostKeyChangedError) Error() string {
+	return fmt.Sprintf(`the host key for %s has changed!
+Someone could be eavesdropping on you right now (man-in-the-middle attack) or the server has been rebuilt.
+The fingerprint of the key sent by the server is %s.
+The known key is in %s (%s)`, e.Host, e.Fingerprint, e.File, strings.Join(e.Known, ", "))
+}
+
+// IsHostKeyChanged returns true if the connection failed because the host key has changed
+func IsHostKeyChanged(err error) bool {
+	var changed *HostKeyChangedError
+	return errors.As(err, &changed)
+}
+
+var knownHostsLock sync.Mutex
+
+// KnownHostsCallback checks the host keys against the known hosts file. Hosts not in
+// the file yet are trusted on first use and added to it. A host with a different key
+// fails with a *HostKeyChangedError
+func KnownHostsCallback(file string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		knownHostsLock.Lock()
+		defer knownHostsLock.Unlock()
+
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(file, os.O_RDONLY|os.O_CREATE, 0600)
+		if err != nil {
+			return err
+		}
+		f.Close()
+
+		// loaded on every connection so hosts added by other connections are picked up
+		check, err := knownhosts.New(file)
+		if err != nil {
+			return err
+		}
+
+		err = check(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+
+		if len(keyErr.Want) > 0 {
+			changed := &HostKeyChangedError{
+				Host:        hostname,
+				File:        file,
+				Fingerprint: ssh.FingerprintSHA256(key),
+			}
+			for _, known := range keyErr.Want {
+				changed.Known = append(changed.Known, fmt.Sprintf("line %d", known.Line))
+			}
+			return changed
+		}
+
+		// first time we see this host
+		f, err = os.OpenFile(file, os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+		return err
+	}
+}
+
+// ForgetHost removes the keys of the host from the known hosts file. It returns
+// the number of keys removed
+func ForgetHost(file string, host string) (int, error) {
+	knownHostsLock.Lock()
+	defer knownHostsLock.Unlock()
+
+	buf, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	host = knownhosts.Normalize(HostPort(host))
+	var kept []string
+	removed := 0
+	scanner := bufio.NewScanner(strings.NewReader(string(buf)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if fields := strings.Fields(line); len(fields) > 0 && knownHost(fields[0], host) {
+			removed++
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	content := strings.Join(kept, "\n")
+	if len(kept) > 0 {
+		content += "\n"
+	}
+	return removed, ioutil.WriteFile(file, []byte(content), 0600)
+}
+
+func knownHost(hosts string, host string) bool {
+	for _, h := range strings.Split(hosts, ",") {
+		if knownhosts.Normalize(h) == host {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+//go:build !windows
+// +build !windows
+
+package sshclient
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// watchWindowSize reports the new size of the terminal every time it is resized
+func watchWindowSize(fd int) (<-chan WindowSize, func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+
+	result := make(chan WindowSize)
+	done := make(chan struct{})
+	go func() {
+		defer close(result)
+		for {
+			select {
+			case <-signals:
+				if width, height, err := term.GetSize(fd); err == nil {
+					select {
+					case result <- WindowSize{Width: width, Height: height}:
+					case <-done:
+						return
+					}
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return result, func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -0,0 +1,43 @@
+//go:build windows
+// +build windows
+
+package sshclient
+
+import (
+	"time"
+
+	"golang.org/x/term"
+)
+
+// watchWindowSize polls the size of the console as there is no SIGWINCH on Windows
+func watchWindowSize(fd int) (<-chan WindowSize, func()) {
+	result := make(chan WindowSize)
+	done := make(chan struct{})
+	go func() {
+		defer close(result)
+		width, height, _ := term.GetSize(fd)
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w, h, err := term.GetSize(fd)
+				if err != nil || (w == width && h == height) {
+					continue
+				}
+				width, height = w, h
+				select {
+				case result <- WindowSize{Width: width, Height: height}:
+				case <-done:
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return result, func() {
+		close(done)
+	}
+}
@@ -0,0 +1,124 @@
+package sshclient
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/term"
+)
+
+// WindowSize is the size of the local terminal
+type WindowSize struct {
+	Width  int
+	Height int
+}
+
+// Pty describes the pseudo terminal requested for a command
+type Pty struct {
+	Term string
+	Size WindowSize
+	// Resize carries the changes of the local terminal size to the server. Can be nil
+	Resize <-chan WindowSize
+}
+
+// Run runs the command on the server without a terminal
+func (c *Client) Run(command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return c.Exec(command, nil, stdin, stdout, stderr)
+}
+
+// Exec runs the command on the server, with a pseudo terminal if pty is not nil.
+// An empty command starts the login shell of the user. A command that exits with
+// a non-zero status returns an *ssh.ExitError (see ExitStatus)
+func (c *Client) Exec(command string, pty *Pty, stdin io.Reader, stdout, stderr io.Writer) error {
+	session, err := c.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	if c.config.ForwardAgent && c.agent != nil {
+		if err := agent.RequestAgentForwarding(session); err != nil {
+			c.logf("Unable to forward the ssh agent: %s", err)
+		}
+	}
+
+	if pty != nil {
+		modes := ssh.TerminalModes{
+			ssh.ECHO:          1,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		}
+		if err := session.RequestPty(pty.Term, pty.Size.Height, pty.Size.Width, modes); err != nil {
+			return err
+		}
+		if pty.Resize != nil {
+			go func() {
+				for size := range pty.Resize {
+					session.WindowChange(size.Height, size.Width)
+				}
+			}()
+		}
+	}
+
+	session.Stdout = stdout
+	session.Stderr = stderr
+	if stdin != nil {
+		// not using session.Stdin as Wait would block until the next read from stdin returns
+		in, err := session.StdinPipe()
+		if err != nil {
+			return err
+		}
+		go func() {
+			io.Copy(in, stdin)
+			in.Close()
+		}()
+	}
+
+	if command == "" {
+		err = session.Shell()
+	} else {
+		err = session.Start(command)
+	}
+	if err != nil {
+		return err
+	}
+
+	return session.Wait()
+}
+
+// Shell runs the command (or the login shell if command is empty) on the server with
+// the local terminal attached to it, like ssh -t. If stdin is not a terminal the command
+// is run without one
+func (c *Client) Shell(command string, stdin *os.File, stdout, stderr io.Writer) error {
+	fd := int(stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return c.Exec(command, nil, stdin, stdout, stderr)
+	}
+
+	width, height, err := term.GetSize(fd)
+	if err != nil {
+		width, height = 80, 24
+	}
+
+	termType := os.Getenv("TERM")
+	if termType == "" {
+		termType = "xterm-256color"
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	resize, stop := watchWindowSize(fd)
+	defer stop()
+
+	return c.Exec(command, &Pty{
+		Term:   termType,
+		Size:   WindowSize{Width: width, Height: height},
+		Resize: resize,
+	}, stdin, stdout, stderr)
+}
@@ -0,0 +1,119 @@
+package sshclient
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/pkg/sftp"
+)
+
+// Upload copies a local file or directory to the server (like scp -r). If remotePath
+// is an existing directory the file is copied into it
+func (c *Client) Upload(localPath string, remotePath string) error {
+	client, err := sftp.NewClient(c.Client)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if info, err := client.Stat(remotePath); err == nil && info.IsDir() {
+		remotePath = path.Join(remotePath, filepath.Base(localPath))
+	}
+
+	return filepath.Walk(localPath, func(local string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localPath, local)
+		if err != nil {
+			return err
+		}
+		remote := path.Join(remotePath, filepath.ToSlash(rel))
+
+		if info.IsDir() {
+			if err := client.MkdirAll(remote); err != nil {
+				return err
+			}
+			return client.Chmod(remote, info.Mode().Perm())
+		}
+		c.logf("Uploading %s to %s", local, remote)
+		return uploadFile(client, local, remote, info.Mode().Perm())
+	})
+}
+
+// Download copies a file or directory from the server to the local machine (like scp -r).
+// If localPath is an existing directory the file is copied into it
+func (c *Client) Download(remotePath string, localPath string) error {
+	client, err := sftp.NewClient(c.Client)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		localPath = filepath.Join(localPath, path.Base(remotePath))
+	}
+
+	walker := client.Walk(remotePath)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(remotePath), filepath.FromSlash(walker.Path()))
+		if err != nil {
+			return err
+		}
+		local := filepath.Join(localPath, rel)
+
+		info := walker.Stat()
+		if info.IsDir() {
+			if err := os.MkdirAll(local, info.Mode().Perm()|0700); err != nil {
+				return err
+			}
+			continue
+		}
+		c.logf("Downloading %s to %s", walker.Path(), local)
+		if err := downloadFile(client, walker.Path(), local, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func uploadFile(client *sftp.Client, local, remote string, mode os.FileMode) error {
+	src, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := client.OpenFile(remote, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return err
+	}
+	return dst.Chmod(mode)
+}
+
+func downloadFile(client *sftp.Client, remote, local string, mode os.FileMode) error {
+	src, err := client.Open(remote)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(local, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
@@ -0,0 +1,148 @@
+package sshclient
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	socksVersion      = 5
+	socksNoAuth       = 0
+	socksNoAcceptable = 0xff
+	socksConnect      = 1
+
+	socksIPv4   = 1
+	socksDomain = 3
+	socksIPv6   = 4
+
+	socksSucceeded           = 0
+	socksHostUnreachable     = 4
+	socksCommandNotSupported = 7
+	socksAddressNotSupported = 8
+)
+
+// socksHandshakeTimeout is the time allowed for a client to send its SOCKS request
+const socksHandshakeTimeout = 30 * time.Second
+
+// ServeSOCKS runs a SOCKS5 proxy on the local listener that connects to the requested
+// addresses from the server (like ssh -D), so anything the server can reach, including
+// private IPs and names only its DNS can resolve, can be reached locally. Like Forward, it
+// returns ErrConnectionLost if the connection to the server drops
+func (c *Client) ServeSOCKS(listener net.Listener) error {
+	return c.serve(listener, func(conn net.Conn) {
+		if err := c.socksConn(conn); err != nil {
+			c.logf("SOCKS: %s", err)
+		}
+	})
+}
+
+func (c *Client) socksConn(local net.Conn) error {
+	defer local.Close()
+
+	local.SetDeadline(time.Now().Add(socksHandshakeTimeout))
+	address, err := socksHandshake(local)
+	if err != nil {
+		return err
+	}
+	local.SetDeadline(time.Time{})
+
+	remote, err := c.Dial("tcp", address)
+	if err != nil {
+		socksReply(local, socksHostUnreachable)
+		return fmt.Errorf("unable to connect to %s: %s", address, err)
+	}
+	defer remote.Close()
+
+	if err := socksReply(local, socksSucceeded); err != nil {
+		return err
+	}
+	c.logf("SOCKS: connected to %s", address)
+
+	pipe(local, remote)
+	return nil
+}
+
+// socksHandshake agrees on no authentication and reads the address of a CONNECT request
+func socksHandshake(conn net.Conn) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+	if header[0] != socksVersion {
+		return "", fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+	noAuth := false
+	for _, method := range methods {
+		if method == socksNoAuth {
+			noAuth = true
+		}
+	}
+	if !noAuth {
+		conn.Write([]byte{socksVersion, socksNoAcceptable})
+		return "", errors.New("the client requires authentication")
+	}
+	if _, err := conn.Write([]byte{socksVersion, socksNoAuth}); err != nil {
+		return "", err
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return "", err
+	}
+	if request[0] != socksVersion {
+		return "", fmt.Errorf("unsupported SOCKS version %d", request[0])
+	}
+	if request[1] != socksConnect {
+		socksReply(conn, socksCommandNotSupported)
+		return "", fmt.Errorf("unsupported SOCKS command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case socksIPv4, socksIPv6:
+		ip := make([]byte, net.IPv4len)
+		if request[3] == socksIPv6 {
+			ip = make([]byte, net.IPv6len)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case socksDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", err
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	default:
+		socksReply(conn, socksAddressNotSupported)
+		return "", fmt.Errorf("unsupported SOCKS address type %d", request[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", err
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// socksReply answers a request. The bound address is not known through the server so it is
+// left empty, which clients ignore
+func socksReply(conn net.Conn, status byte) error {
+	_, err := conn.Write([]byte{socksVersion, status, 0, socksIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
@@ -0,0 +1,12 @@
+package sshclient_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestSshclient(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "sshclient")
+}
@@ -0,0 +1,289 @@
+package sshclient
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SSH client", func() {
+	var dir string
+	var server *testServer
+	var config Config
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "sshclient")
+		Expect(err).NotTo(HaveOccurred())
+
+		keyFile, key := newTestKey(dir)
+		server = startTestServer(key)
+		config = Config{Address: server.Address, User: "cloud66-user", KeyFile: keyFile, HostKeyCallback: ssh.FixedHostKey(server.HostKey.PublicKey())}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should run a command and return its output", func() {
+		client, err := Dial(config)
+		Expect(err).NotTo(HaveOccurred())
+		defer client.Close()
+
+		var stdout bytes.Buffer
+		Expect(client.Run("uptime", nil, &stdout, ioutil.Discard)).To(Succeed())
+		Expect(stdout.String()).To(Equal("ran uptime\n"))
+	})
+
+	It("should send stdin to the command", func() {
+		client, err := Dial(config)
+		Expect(err).NotTo(HaveOccurred())
+		defer client.Close()
+
+		var stdout bytes.Buffer
+		Expect(client.Run("cat", strings.NewReader("hello\n"), &stdout, ioutil.Discard)).To(Succeed())
+		Expect(stdout.String()).To(Equal("hello\n"))
+	})
+
+	It("should return the exit status of failed commands", func() {
+		client, err := Dial(config)
+		Expect(err).NotTo(HaveOccurred())
+		defer client.Close()
+
+		var stderr bytes.Buffer
+		err = client.Run("exit 3", nil, ioutil.Discard, &stderr)
+		Expect(ExitStatus(err)).To(Equal(3))
+		Expect(stderr.String()).To(Equal("failed\n"))
+	})
+
+	It("should request a pseudo terminal", func() {
+		client, err := Dial(config)
+		Expect(err).NotTo(HaveOccurred())
+		defer client.Close()
+
+		var stdout bytes.Buffer
+		pty := &Pty{Term: "xterm", Size: WindowSize{Width: 120, Height: 40}}
+		Expect(client.Exec("", pty, nil, &stdout, ioutil.Discard)).To(Succeed())
+		Expect(stdout.String()).To(Equal("pty xterm 120x40\nran shell\n"))
+	})
+
+	It("should fail with an unknown key", func() {
+		otherDir, err := ioutil.TempDir(dir, "other")
+		Expect(err).NotTo(HaveOccurred())
+		config.KeyFile, _ = newTestKey(otherDir)
+
+		_, err = Dial(config)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("unable to authenticate"))
+	})
+
+	It("should fail with a missing key", func() {
+		config.KeyFile = filepath.Join(dir, "missing")
+
+		_, err := Dial(config)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix("unable to read the key"))
+	})
+
+	It("should not connect without a host key callback", func() {
+		config.HostKeyCallback = nil
+
+		_, err := Dial(config)
+		Expect(err).To(MatchError("no host key callback given to check the server"))
+	})
+
+	It("should connect through a gateway", func() {
+		gateway := config
+		target := startTestServer(server.authorized)
+		defer target.Close()
+
+		config.Address = target.Address
+		config.HostKeyCallback = ssh.FixedHostKey(target.HostKey.PublicKey())
+		config.Gateway = &gateway
+		client, err := Dial(config)
+		Expect(err).NotTo(HaveOccurred())
+		defer client.Close()
+
+		var stdout bytes.Buffer
+		Expect(client.Run("hostname", nil, &stdout, ioutil.Discard)).To(Succeed())
+		Expect(stdout.String()).To(Equal("ran hostname\n"))
+	})
+
+	It("should forward local ports", func() {
+		echo, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer echo.Close()
+		go func() {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			conn.Write([]byte("echo " + line))
+		}()
+
+		client, err := Dial(config)
+		Expect(err).NotTo(HaveOccurred())
+		defer client.Close()
+
+		local, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		forwarding := make(chan error, 1)
+		go func() {
+			forwarding <- client.Forward(local, echo.Addr().String())
+		}()
+
+		conn, err := net.Dial("tcp", local.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+		conn.Write([]byte("hello\n"))
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		Expect(err).NotTo(HaveOccurred())
+		Expect(line).To(Equal("echo hello\n"))
+
+		client.Close()
+		Eventually(forwarding, 5).Should(Receive(Equal(ErrConnectionLost)))
+		local.Close()
+	})
+
+	It("should proxy SOCKS5 connections through the server", func() {
+		echo, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer echo.Close()
+		go func() {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			conn.Write([]byte("echo " + line))
+		}()
+		echoPort := echo.Addr().(*net.TCPAddr).Port
+
+		client, err := Dial(config)
+		Expect(err).NotTo(HaveOccurred())
+		defer client.Close()
+
+		local, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer local.Close()
+		go client.ServeSOCKS(local)
+
+		conn, err := net.Dial("tcp", local.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+
+		conn.Write([]byte{5, 1, 0})
+		reply := make([]byte, 2)
+		_, err = io.ReadFull(reader, reply)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reply).To(Equal([]byte{5, 0}))
+
+		domain := "127.0.0.1"
+		request := append([]byte{5, 1, 0, 3, byte(len(domain))}, domain...)
+		conn.Write(append(request, byte(echoPort>>8), byte(echoPort)))
+		reply = make([]byte, 10)
+		_, err = io.ReadFull(reader, reply)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reply[1]).To(Equal(byte(0)))
+
+		conn.Write([]byte("hello\n"))
+		line, err := reader.ReadString('\n')
+		Expect(err).NotTo(HaveOccurred())
+		Expect(line).To(Equal("echo hello\n"))
+	})
+
+	It("should upload and download directories", func() {
+		source := filepath.Join(dir, "source")
+		Expect(os.MkdirAll(filepath.Join(source, "nested"), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(source, "nested", "file.txt"), []byte("content"), 0640)).To(Succeed())
+
+		remote := filepath.Join(dir, "remote")
+		Expect(os.Mkdir(remote, 0755)).To(Succeed())
+		local := filepath.Join(dir, "local")
+		Expect(os.Mkdir(local, 0755)).To(Succeed())
+
+		client, err := Dial(config)
+		Expect(err).NotTo(HaveOccurred())
+		defer client.Close()
+
+		Expect(client.Upload(source, remote)).To(Succeed())
+		buf, err := ioutil.ReadFile(filepath.Join(remote, "source", "nested", "file.txt"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(buf)).To(Equal("content"))
+
+		Expect(client.Download(filepath.Join(remote, "source"), local)).To(Succeed())
+		info, err := os.Stat(filepath.Join(local, "source", "nested", "file.txt"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0640)))
+
+		Expect(client.Download(filepath.Join(remote, "source", "nested", "file.txt"), filepath.Join(local, "copy.txt"))).To(Succeed())
+		buf, err = ioutil.ReadFile(filepath.Join(local, "copy.txt"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(buf)).To(Equal("content"))
+	})
+
+	Context("with a known hosts file", func() {
+		var knownHosts string
+
+		BeforeEach(func() {
+			knownHosts = filepath.Join(dir, "known_hosts", "stack")
+			config.HostKeyCallback = KnownHostsCallback(knownHosts)
+		})
+
+		It("should trust and record the host key on first use", func() {
+			client, err := Dial(config)
+			Expect(err).NotTo(HaveOccurred())
+			client.Close()
+
+			buf, err := ioutil.ReadFile(knownHosts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.Count(string(buf), "\n")).To(Equal(1))
+			Expect(string(buf)).To(HavePrefix("[127.0.0.1]:"))
+
+			client, err = Dial(config)
+			Expect(err).NotTo(HaveOccurred())
+			client.Close()
+
+			buf, err = ioutil.ReadFile(knownHosts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.Count(string(buf), "\n")).To(Equal(1))
+		})
+
+		It("should refuse a changed host key until the host is forgotten", func() {
+			client, err := Dial(config)
+			Expect(err).NotTo(HaveOccurred())
+			client.Close()
+
+			// a new server on the same address
+			server.Close()
+			server = startTestServer(server.authorized, server.Address)
+
+			_, err = Dial(config)
+			Expect(err).To(HaveOccurred())
+			Expect(IsHostKeyChanged(err)).To(BeTrue())
+
+			removed, err := ForgetHost(knownHosts, server.Address)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(Equal(1))
+
+			client, err = Dial(config)
+			Expect(err).NotTo(HaveOccurred())
+			client.Close()
+		})
+	})
+})
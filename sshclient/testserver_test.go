@@ -0,0 +1,223 @@
+package sshclient
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is an in-process ssh server. Commands are not run: they are echoed back
+// as "ran <command>" except for "cat" which echoes stdin and "exit <n>" which exits with n.
+// It also serves port forwarding and sftp on the local file system
+type testServer struct {
+	Address    string
+	HostKey    ssh.Signer
+	authorized ssh.PublicKey
+	listener   net.Listener
+}
+
+// newTestKey writes a new private key to dir and returns its path and public key
+func newTestKey(dir string) (string, ssh.PublicKey) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		panic(err)
+	}
+	keyFile := filepath.Join(dir, "key")
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		panic(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		panic(err)
+	}
+	return keyFile, signer.PublicKey()
+}
+
+// startTestServer starts a server with a new host key, on the given address or a random port
+func startTestServer(authorized ssh.PublicKey, address ...string) *testServer {
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		panic(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == string(authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown key for %s", conn.User())
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listenAddress := "127.0.0.1:0"
+	if len(address) > 0 {
+		listenAddress = address[0]
+	}
+	listener, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		panic(err)
+	}
+
+	s := &testServer{Address: listener.Addr().String(), HostKey: hostKey, authorized: authorized, listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) Close() {
+	s.listener.Close()
+}
+
+func (s *testServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			go s.session(newChannel)
+		case "direct-tcpip":
+			go s.directTCPIP(newChannel)
+		default:
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel")
+		}
+	}
+}
+
+func (s *testServer) session(newChannel ssh.NewChannel) {
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	pty := ""
+	for req := range reqs {
+		switch req.Type {
+		case "pty-req":
+			var payload struct {
+				Term   string
+				Width  uint32
+				Height uint32
+				PixelW uint32
+				PixelH uint32
+				Modes  string
+			}
+			ssh.Unmarshal(req.Payload, &payload)
+			pty = fmt.Sprintf("pty %s %dx%d\n", payload.Term, payload.Width, payload.Height)
+			req.Reply(true, nil)
+		case "exec":
+			var payload struct{ Command string }
+			ssh.Unmarshal(req.Payload, &payload)
+			req.Reply(true, nil)
+			exit(channel, run(channel, pty, payload.Command))
+			return
+		case "shell":
+			req.Reply(true, nil)
+			exit(channel, run(channel, pty, "shell"))
+			return
+		case "subsystem":
+			var payload struct{ Name string }
+			ssh.Unmarshal(req.Payload, &payload)
+			if payload.Name != "sftp" {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			server, err := sftp.NewServer(channel)
+			if err != nil {
+				return
+			}
+			server.Serve()
+			return
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+func run(channel ssh.Channel, pty string, command string) int {
+	io.WriteString(channel, pty)
+	switch {
+	case command == "cat":
+		io.Copy(channel, channel)
+	case strings.HasPrefix(command, "exit "):
+		status, _ := strconv.Atoi(strings.TrimPrefix(command, "exit "))
+		io.WriteString(channel.Stderr(), "failed\n")
+		return status
+	default:
+		io.WriteString(channel, "ran "+command+"\n")
+	}
+	return 0
+}
+
+func exit(channel ssh.Channel, status int) {
+	channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+}
+
+func (s *testServer) directTCPIP(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	defer target.Close()
+
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	defer channel.Close()
+
+	done := make(chan struct{})
+	go func() {
+		io.Copy(target, channel)
+		target.(*net.TCPConn).CloseWrite()
+		close(done)
+	}()
+	io.Copy(channel, target)
+	channel.CloseWrite()
+	<-done
+}
@@ -30,6 +30,10 @@ func runStackConfigurationList(c *cli.Context) {
 }
 
 func printConfigurationList(configurations []cloud66.Configuration) {
+	if printStructured(configurations) {
+		return
+	}
+
 	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
 	defer w.Flush()
 	listRec(w,
@@ -133,7 +137,10 @@ func runStackConfigurationUpload(c *cli.Context) {
 	asyncRes, err := client.ConfigurationUpload(stack.Uid, theType, commitMessage, body, mustApply)
 	must(err)
 
-	genericRes, err := client.WaitStackAsyncAction(asyncRes.Id, stack.Uid, 5*time.Second, 20*time.Minute, true)
+	genericRes, err := waitStackAction(asyncRes.Id, stack.Uid, 5*time.Second, 20*time.Minute, true)
+	if actionNotWaited(err, asyncRes.Id) {
+		return
+	}
 	must(err)
 
 	var successMessage string
@@ -174,7 +181,10 @@ func runStackConfigurationApply(c *cli.Context) {
 
 	asyncRes, err := client.ConfigurationApply(stack.Uid, theType)
 	must(err)
-	genericRes, err := client.WaitStackAsyncAction(asyncRes.Id, stack.Uid, 5*time.Second, 20*time.Minute, true)
+	genericRes, err := waitStackAction(asyncRes.Id, stack.Uid, 5*time.Second, 20*time.Minute, true)
+	if actionNotWaited(err, asyncRes.Id) {
+		return
+	}
 	must(err)
 	successMessage := "Configuration applied"
 	printGenericResponseCustom(*genericRes, successMessage, "")
@@ -73,6 +73,10 @@ func runServiceYamlList(c *cli.Context, stackUid string) {
 }
 
 func printServiceYamlList(w io.Writer, serviceYamls []cloud66.ServiceYaml) {
+	if printStructured(serviceYamls) {
+		return
+	}
+
 	for _, a := range serviceYamls {
 		if a.Uid != "" {
 			listRec(w,
@@ -95,6 +99,10 @@ func runManifestYamlList(c *cli.Context, stackUid string) {
 }
 
 func printManifestYamlList(w io.Writer, manifestYamls []cloud66.ManifestYaml) {
+	if printStructured(manifestYamls) {
+		return
+	}
+
 	for _, a := range manifestYamls {
 		if a.Uid != "" {
 			listRec(w,
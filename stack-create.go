@@ -103,7 +103,7 @@ func startCreateStack(name, environment, serviceYaml, manifestYaml string, targe
 }
 
 func endCreateStack(asyncId int, stackUid string) (*cloud66.GenericResponse, error) {
-	return client.WaitStackAsyncAction(asyncId, stackUid, 5*time.Second, 20*time.Minute, false)
+	return waitAction(asyncId, stackUid, 5*time.Second, 20*time.Minute, false)
 }
 
 func initiateStackBuild(stackUid string) error {
@@ -6,12 +6,13 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66-oss/cx/term"
 	"github.com/cloud66/cli"
-	"github.com/cloud66/wray"
 	"github.com/mgutz/ansi"
 )
 
@@ -23,45 +24,166 @@ type logMessage struct {
 	Deployment bool      `json:"is_cap"`
 }
 
-type tailHandler struct {
-	totalMessages int
-	messagesShown int
+// logSeverities are the names of the severities of the log messages, in order
+var logSeverities = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "IMPORTANT", "FATAL"}
+
+// listenOptions select and format the messages shown by listen
+type listenOptions struct {
+	MinSeverity    int
+	DeploymentOnly bool
+	JSON           bool
+	// UntilIdle stops listening once there has been no message for that long, unless 0
+	UntilIdle time.Duration
+	// Hooks are run on the events. Nothing is run when nil
+	Hooks *listenHooks
+}
+
+var listenFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "min-severity",
+		Usage: "only show the messages with this severity or higher (trace, debug, info, warn, error, important or fatal)",
+	},
+	cli.BoolFlag{
+		Name:  "deployment-only",
+		Usage: "only show the messages of deployments",
+	},
+	cli.BoolFlag{
+		Name:  "json",
+		Usage: "show each message as a line of JSON",
+	},
+	cli.DurationFlag{
+		Name:  "until-idle",
+		Usage: "stop once there has been no message for this long (ie. 5m)",
+	},
+	cli.StringSliceFlag{
+		Name:  "on",
+		Usage: "run a command on an event, as event=command. The events are message, error, deploy-started, deploy-finished and deploy-failed. Repeatable",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringSliceFlag{
+		Name:  "webhook",
+		Usage: "post the events as JSON to this URL. Only the events given with --on if any. Repeatable",
+		Value: &cli.StringSlice{},
+	},
+}
+
+func listenOptionsFrom(c *cli.Context) (listenOptions, error) {
+	options := listenOptions{
+		DeploymentOnly: c.Bool("deployment-only"),
+		JSON:           c.Bool("json"),
+		UntilIdle:      c.Duration("until-idle"),
+	}
+	if c.String("min-severity") != "" {
+		severity, err := parseSeverity(c.String("min-severity"))
+		if err != nil {
+			return options, err
+		}
+		options.MinSeverity = severity
+	}
+	hooks, err := newListenHooks(c.StringSlice("on"), c.StringSlice("webhook"))
+	if err != nil {
+		return options, err
+	}
+	options.Hooks = hooks
+	return options, nil
+}
+
+// parseSeverity takes the name or the number of a severity
+func parseSeverity(value string) (int, error) {
+	for idx, name := range logSeverities {
+		if strings.EqualFold(name, value) || (strings.EqualFold(value, "warning") && name == "WARN") {
+			return idx, nil
+		}
+	}
+	if severity, err := strconv.Atoi(value); err == nil && severity >= 0 && severity < len(logSeverities) {
+		return severity, nil
+	}
+	return 0, fmt.Errorf("invalid severity %s. Use one of %s", value, strings.ToLower(strings.Join(logSeverities, ", ")))
 }
 
 func runListen(c *cli.Context) {
 	stack := mustStack(c)
 
-	StartListen(stack)
+	options, err := listenOptionsFrom(c)
+	if err != nil {
+		printFatal(err.Error())
+	}
+
+	listenStacks([]cloud66.Stack{*stack}, options)
 }
 
+// StartListen shows the log messages of the stack until interrupted
 func StartListen(stack *cloud66.Stack) {
+	listenStacks([]cloud66.Stack{*stack}, listenOptions{})
+}
+
+// listenSource is a stack being listened to
+type listenSource struct {
+	stack cloud66.Stack
+	// prefix is put in front of the messages when listening to more than one stack
+	prefix string
+	deploy deploymentTracker
+}
+
+// listenStacks shows the log messages of the stacks until interrupted, or idle with UntilIdle
+func listenStacks(stacks []cloud66.Stack, options listenOptions) {
 	if debugMode {
 		fmt.Printf("Connecting to Faye on %s\n", selectedProfile.FayeEndpoint)
 	}
 
-	//	sigChan := make(chan os.Signal, 1)
-	//  signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	channel := "/realtime/" + stack.Uid + "/*"
-
-	wray.RegisterTransports([]wray.Transport{&wray.HttpTransport{}})
-
-	fc := wray.NewFayeClient(selectedProfile.FayeEndpoint)
-	sub := fc.Subscribe(channel, true, handleMessage)
-	if debugMode {
-		fmt.Printf("Subscribed to %s\n", sub)
+	sources := listenSources(stacks, term.IsANSI(os.Stdout))
+	var channels []string
+	for _, stack := range stacks {
+		channels = append(channels, "/realtime/"+stack.Uid+"/*")
 	}
-	go fc.Listen()
+	realtime := newRealtimeClient(selectedProfile.FayeEndpoint, channels...)
+	realtime.status = printRealtimeStatus
+
+	events := make(chan realtimeEvent)
+	stop := make(chan struct{})
+	defer close(stop)
+	go realtime.Listen(events, stop)
 
 	// handle interrupts
 	hupChan := make(chan os.Signal, 1)
-	termChan := make(chan os.Signal, 1)
 	signal.Notify(hupChan, syscall.SIGHUP)
-	signal.Notify(termChan, syscall.SIGINT, syscall.SIGTERM)
+	stopped := interrupted()
+
+	// the hooks that are still running are finished before returning. Another Ctrl-C stops cx
+	// if they hang
+	defer func() {
+		signal.Stop(hupChan)
+		if !options.Hooks.wait(listenHooksTimeout) {
+			printWarning("The hooks didn't finish in %s and are left running", listenHooksTimeout)
+		}
+	}()
+
+	var idle <-chan time.Time
+	var idleTimer *time.Timer
+	if options.UntilIdle > 0 {
+		idleTimer = time.NewTimer(options.UntilIdle)
+		defer idleTimer.Stop()
+		idle = idleTimer.C
+	}
 
 	for {
 		select {
-		case <-termChan:
+		case event := <-events:
+			// the channels are /realtime/<stack uid>/<event>
+			parts := strings.Split(event.Channel, "/")
+			if len(parts) < 3 || sources[parts[2]] == nil {
+				continue
+			}
+			handleMessage(event, sources[parts[2]], options)
+			if idleTimer != nil {
+				if !idleTimer.Stop() {
+					<-idleTimer.C
+				}
+				idleTimer.Reset(options.UntilIdle)
+			}
+		case <-idle:
+			return
+		case <-stopped:
 			return
 		case <-hupChan:
 			return
@@ -69,48 +191,110 @@ func StartListen(stack *cloud66.Stack) {
 	}
 }
 
-func handleMessage(msg wray.Message) {
-	redColor := ansi.ColorFunc("red+h")
-	capColor := ansi.ColorFunc("yellow")
-	infoColor := ansi.ColorFunc("white")
+// listenSources returns the sources of the stacks by uid. The stacks are told apart by their
+// name, and their environment when there are stacks with the same name
+func listenSources(stacks []cloud66.Stack, colored bool) map[string]*listenSource {
+	sources := map[string]*listenSource{}
+	if len(stacks) == 1 {
+		sources[stacks[0].Uid] = &listenSource{stack: stacks[0]}
+		return sources
+	}
+
+	names := map[string]int{}
+	for _, stack := range stacks {
+		names[stack.Name]++
+	}
+	labels := make([]string, len(stacks))
+	width := 0
+	for idx, stack := range stacks {
+		labels[idx] = stack.Name
+		if names[stack.Name] > 1 {
+			labels[idx] = stack.Name + " (" + stack.Environment + ")"
+		}
+		if len(labels[idx]) > width {
+			width = len(labels[idx])
+		}
+	}
+
+	for idx, stack := range stacks {
+		prefix := fmt.Sprintf("%-*s", width, labels[idx])
+		if colored {
+			prefix = ansi.Color(prefix, tailColors[idx%len(tailColors)])
+		}
+		sources[stack.Uid] = &listenSource{stack: stack, prefix: prefix + " | "}
+	}
+	return sources
+}
 
-	s, err := strconv.Unquote(msg.Data)
+func handleMessage(event realtimeEvent, source *listenSource, options listenOptions) {
+	payload, err := realtimePayload(event.Data)
 	if err != nil {
-		fmt.Println("Error: ", err)
+		if debugMode {
+			printError(err.Error())
+		}
+		return
 	}
 	var m logMessage
-	err = json.Unmarshal([]byte(s), &m)
-	if err != nil {
-		fmt.Println("Error:", err)
-	}
-
-	var level string
-	var colorFunc func(string) string
-
-	switch m.Severity {
-	case 0:
-		level = "TRACE"
-		colorFunc = infoColor
-	case 1:
-		level = "DEBUG"
-		colorFunc = infoColor
-	case 2:
-		level = "INFO"
-		colorFunc = infoColor
-	case 3:
-		level = "WARN"
-		colorFunc = capColor
-	case 4:
-		level = "ERROR"
-		colorFunc = redColor
-	case 5:
-		level = "IMPORTANT"
-		colorFunc = redColor
-	case 6:
-		level = "FATAL"
-		colorFunc = redColor
-	}
-
-	fmt.Println(colorFunc(fmt.Sprintf("%s [%s] - %s", m.Time, level, m.Message)))
+	if err := json.Unmarshal(payload, &m); err != nil {
+		if debugMode {
+			printError("invalid message %s: %s", payload, err)
+		}
+		return
+	}
+
+	stack := source.stack
+	if m.Deployment && options.Hooks != nil {
+		source.deploy.seen(stack, options.Hooks)
+	}
 
+	if m.Severity < options.MinSeverity || (options.DeploymentOnly && !m.Deployment) {
+		return
+	}
+
+	level := "UNKNOWN"
+	if m.Severity >= 0 && m.Severity < len(logSeverities) {
+		level = logSeverities[m.Severity]
+	}
+	if options.Hooks != nil {
+		hookEvent := listenEvent{Event: "message", Stack: stack.Name, StackUid: stack.Uid, Environment: stack.Environment, Severity: level, Message: m.Message, Time: m.Time}
+		options.Hooks.fire(hookEvent)
+		if m.Severity >= 4 {
+			hookEvent.Event = "error"
+			options.Hooks.fire(hookEvent)
+		}
+	}
+
+	if options.JSON {
+		fmt.Println(string(listenJSON(payload, source.stack)))
+		return
+	}
+
+	line := fmt.Sprintf("%s [%s] - %s", m.Time, level, m.Message)
+	if term.IsANSI(os.Stdout) {
+		switch {
+		case m.Severity >= 4:
+			line = ansi.Color(line, "red+h")
+		case m.Severity == 3:
+			line = ansi.Color(line, "yellow")
+		default:
+			line = ansi.Color(line, "white")
+		}
+	}
+	fmt.Println(source.prefix + line)
+}
+
+// listenJSON adds the stack to the JSON of a message
+func listenJSON(payload []byte, stack cloud66.Stack) []byte {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return payload
+	}
+	fields["stack"] = stack.Name
+	fields["stack_uid"] = stack.Uid
+	fields["environment"] = stack.Environment
+	buf, err := json.Marshal(fields)
+	if err != nil {
+		return payload
+	}
+	return buf
 }
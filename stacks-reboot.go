@@ -38,6 +38,9 @@ func executeStackReboot(stack cloud66.Stack, strategy string, group string) {
 		printFatal(err.Error())
 	}
 	genericRes, err := endStackReboot(*asyncId, stack.Uid)
+	if actionNotWaited(err, *asyncId) {
+		return
+	}
 	if err != nil {
 		printFatal(err.Error())
 	}
@@ -54,5 +57,5 @@ func startStackReboot(stackUid string, strategy string, group string) (*int, err
 }
 
 func endStackReboot(asyncId int, stackUid string) (*cloud66.GenericResponse, error) {
-	return client.WaitStackAsyncAction(asyncId, stackUid, 10*time.Second, 30*time.Minute, true)
+	return waitStackAction(asyncId, stackUid, 10*time.Second, 30*time.Minute, true)
 }
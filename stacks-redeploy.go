@@ -1,11 +1,39 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"time"
 
+	"github.com/cloud66-oss/cloud66"
 	"github.com/cloud66/cli"
 )
 
+const (
+	// redeployFailed is the exit code when the deployment fails
+	redeployFailed = 1
+	// redeployTimedOut is the exit code when the deployment doesn't finish in time
+	redeployTimedOut = 3
+	// redeployInterrupted is the exit code when the wait is stopped with Ctrl-C
+	redeployInterrupted = 130
+)
+
+// the states of a stack, as in the stackStatus and healthStatus maps of cloud66
+const (
+	stackStatusFailed             = 2 // STK_FAILED
+	stackStatusQueuedForDeploying = 5 // STK_QUEUED_FOR_DEPLOYING
+	stackStatusDeploying          = 6 // STK_DEPLOYING
+	stackStatusTerminalFailure    = 7 // STK_TERMINAL_FAILURE
+
+	stackHealthBuilding = 1 // HLT_BUILDING
+	stackHealthPartial  = 2 // HLT_PARTIAL
+	stackHealthBroken   = 4 // HLT_BROKEN
+)
+
+var errRedeployTimedOut = errors.New("timed out")
+var errRedeployInterrupted = errors.New("interrupted")
+
 // this is an alias for stacks redeploy command
 var cmdRedeploy = &Command{
 	Name:  "redeploy",
@@ -20,6 +48,15 @@ var cmdRedeploy = &Command{
 			Name:  "listen",
 			Usage: "show stack deployment progress and log output",
 		},
+		cli.BoolFlag{
+			Name:  "wait",
+			Usage: "wait for the deployment to finish, showing its log, and exit with non-zero if it fails",
+		},
+		cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "with --wait, how long to wait for the deployment to finish",
+			Value: 3 * time.Hour,
+		},
 		cli.StringFlag{
 			Name:  "git-ref",
 			Usage: "[classic stacks] git reference",
@@ -58,20 +95,141 @@ func runRedeploy(c *cli.Context) {
 	result, err := client.RedeployStack(stack.Uid, c.String("git-ref"), c.StringSlice("service"))
 	must(err)
 
-	if !c.Bool("listen") || result.Queued {
-		// its queued - just message and exit
-		fmt.Println(result.Message)
-	} else {
+	if !c.Bool("wait") {
+		if !c.Bool("listen") || result.Queued {
+			// its queued - just message and exit
+			fmt.Println(result.Message)
+			return
+		}
+
 		// tail the logs
 		go StartListen(stack)
 
 		stack, err = WaitStackBuild(stack.Uid, false)
 		must(err)
 
-		if stack.HealthCode == 2 || stack.HealthCode == 4 || stack.StatusCode == 2 || stack.StatusCode == 7 {
+		if stackDeployFailed(stack) {
 			printFatal("Completed with some errors!")
-		} else {
-			fmt.Println("Completed successfully!")
+		}
+		fmt.Println("Completed successfully!")
+		return
+	}
+
+	fmt.Println(result.Message)
+	// tail the logs
+	go StartListen(stack)
+
+	// the global --timeout and --poll-interval are used unless --timeout is given to redeploy
+	interval, timeout := waitSettings(10*time.Second, c.Duration("timeout"))
+	if c.IsSet("timeout") {
+		timeout = c.Duration("timeout")
+	}
+	stack, err = waitForRedeploy(stack.Uid, result.Queued, timeout, interval)
+	switch err {
+	case nil:
+	case errRedeployTimedOut:
+		printError("The deployment didn't finish in %s", timeout)
+		os.Exit(redeployTimedOut)
+	case errRedeployInterrupted:
+		os.Exit(redeployInterrupted)
+	default:
+		printFatal(err.Error())
+	}
+
+	if stackDeployFailed(stack) {
+		printError("Completed with some errors! The stack is %s (%s)", stack.Status(), stack.Health())
+		os.Exit(redeployFailed)
+	}
+	fmt.Println("Completed successfully!")
+}
+
+// deploymentWatch follows the status of a stack until the deployment it is waiting for is done
+type deploymentWatch struct {
+	// remaining is the number of deployments to see finish. A queued deployment starts after the
+	// one running now
+	remaining    int
+	deploying    bool
+	lastActivity *time.Time
+	started      bool
+}
+
+func newDeploymentWatch(stack *cloud66.Stack, queued bool) *deploymentWatch {
+	watch := &deploymentWatch{remaining: 1, lastActivity: stack.LastActivity}
+	if queued {
+		watch.remaining = 2
+	}
+	return watch
+}
+
+// update takes the latest status of the stack and returns true once the deployment is done
+func (w *deploymentWatch) update(stack *cloud66.Stack) bool {
+	deploying := stackDeploying(stack)
+	activity := stack.LastActivity != nil && (w.lastActivity == nil || stack.LastActivity.After(*w.lastActivity))
+	w.lastActivity = stack.LastActivity
+
+	switch {
+	case deploying:
+		w.started = true
+	case w.deploying:
+		w.remaining--
+	case activity && w.started:
+		// a whole deployment happened between two checks
+		w.remaining--
+	case activity && !w.started:
+		// the deployment was quicker than the first check
+		w.started = true
+		w.remaining--
+	}
+	w.deploying = deploying
+	return w.remaining <= 0
+}
+
+func stackDeploying(stack *cloud66.Stack) bool {
+	return stack.StatusCode == stackStatusQueuedForDeploying || stack.StatusCode == stackStatusDeploying ||
+		stack.HealthCode == stackHealthBuilding
+}
+
+func stackDeployFailed(stack *cloud66.Stack) bool {
+	return stack.HealthCode == stackHealthPartial || stack.HealthCode == stackHealthBroken ||
+		stack.StatusCode == stackStatusFailed || stack.StatusCode == stackStatusTerminalFailure
+}
+
+// waitForRedeploy checks the stack every interval until the deployment that was just started (or
+// queued) is finished and returns the stack then
+func waitForRedeploy(stackUid string, queued bool, timeout time.Duration, interval time.Duration) (*cloud66.Stack, error) {
+	stack, err := client.FindStackByUid(stackUid)
+	if err != nil {
+		return nil, err
+	}
+	watch := newDeploymentWatch(stack, queued)
+	// the stack might not show the deployment yet
+	watch.update(stack)
+
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	status := stack.Status()
+	for {
+		select {
+		case <-interrupted():
+			return nil, errRedeployInterrupted
+		case <-deadline:
+			return nil, errRedeployTimedOut
+		case <-ticker.C:
+			stack, err = client.FindStackByUid(stackUid)
+			if err != nil {
+				// the API might be unavailable for a moment during the deployment
+				printWarning("Unable to check the stack: %s", err)
+				continue
+			}
+			if stack.Status() != status {
+				status = stack.Status()
+				fmt.Printf("Stack is %s\n", status)
+			}
+			if watch.update(stack) {
+				return stack, nil
+			}
 		}
 	}
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Redeploy wait", func() {
+	before := time.Date(2020, 3, 27, 14, 0, 0, 0, time.UTC)
+	after := before.Add(5 * time.Minute)
+	deployed := &cloud66.Stack{StatusCode: 1, HealthCode: 3, LastActivity: &before}
+	deploying := &cloud66.Stack{StatusCode: 6, HealthCode: 1, LastActivity: &before}
+	finished := &cloud66.Stack{StatusCode: 2, HealthCode: 4, LastActivity: &after}
+
+	It("should wait for the deployment to start and finish", func() {
+		watch := newDeploymentWatch(deployed, false)
+		Expect(watch.update(deployed)).To(BeFalse())
+		Expect(watch.update(deploying)).To(BeFalse())
+		Expect(watch.update(deploying)).To(BeFalse())
+		Expect(watch.update(finished)).To(BeTrue())
+		Expect(stackDeployFailed(finished)).To(BeTrue())
+	})
+
+	It("should notice a deployment that finished between two checks", func() {
+		watch := newDeploymentWatch(deployed, false)
+		Expect(watch.update(deployed)).To(BeFalse())
+		Expect(watch.update(finished)).To(BeTrue())
+	})
+
+	It("should wait for a queued deployment after the running one", func() {
+		watch := newDeploymentWatch(deploying, true)
+		Expect(watch.update(deploying)).To(BeFalse())
+		Expect(watch.update(deployed)).To(BeFalse())
+		Expect(watch.update(deploying)).To(BeFalse())
+		Expect(watch.update(finished)).To(BeTrue())
+	})
+})
@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/cloud66-oss/cloud66"
 	"github.com/cloud66/cli"
@@ -99,6 +100,15 @@ $ cx stacks create --name my_docker_stack --environment production --service_yam
 					Name:  "listen",
 					Usage: "show stack deployment progress and log output",
 				},
+				cli.BoolFlag{
+					Name:  "wait",
+					Usage: "wait for the deployment to finish, showing its log, and exit with non-zero if it fails",
+				},
+				cli.DurationFlag{
+					Name:  "timeout",
+					Usage: "with --wait, how long to wait for the deployment to finish",
+					Value: 3 * time.Hour,
+				},
 				cli.StringFlag{
 					Name:  "environment,e",
 					Usage: "full or partial environment name",
@@ -116,6 +126,13 @@ after the current one is finished.
 -y answers yes to confirmation question if the stack is production.
 --git-ref will redeploy the specific branch, tag or hash git reference [classic stacks]
 --service is a repeateable option to deploy only the specified service(s). Including a reference (separated by a colon) will attempt to deploy that particular reference for that service [docker stacks]
+--wait waits for the deployment to finish while showing its log. If another deployment is running, it waits for
+the queued one too. The command exits with 0 when the deployment succeeds, 1 when it fails, 3 when it doesn't
+finish within --timeout (3h by default) and 130 when it is interrupted.
+--listen shows the log of the deployment until it finishes, unless it is queued behind another one.
+
+Examples:
+$ cx stacks redeploy -s mystack -y --wait --timeout 30m
 `,
 		},
 		cli.Command{
@@ -198,7 +215,7 @@ However, rebasing or forcing a commit doesn't have any association with Cloud 66
 			Name:   "listen",
 			Action: runListen,
 			Usage:  "tails all deployment logs",
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				cli.StringFlag{
 					Name:  "environment,e",
 					Usage: "full or partial environment name",
@@ -207,12 +224,22 @@ However, rebasing or forcing a commit doesn't have any association with Cloud 66
 					Name:  "stack,s",
 					Usage: "full or partial stack name. This can be omitted if the current directory is a stack directory",
 				},
-			},
+			}, listenFlags...),
 			Description: `This acts as a log tail for deployment of a stack so you don't have to follow the deployment on the web.
+It reconnects by itself if the connection drops.
+
+--min-severity only shows the messages with that severity or higher (trace, debug, info, warn, error, important or fatal).
+--deployment-only only shows the messages of deployments.
+--json shows each message as a line of JSON, for scripts.
+--until-idle stops once there has been no message for that long, instead of listening until interrupted.
+--on event=command runs a command on an event and --webhook posts the events as JSON to a URL. See 'cx help listen'.
 
 Examples:
 $ cx stacks listen
 $ cx stacks listen -s mystack
+$ cx stacks listen -s mystack --min-severity warn --deployment-only
+$ cx stacks listen -s mystack --json --until-idle 10m | jq .message
+$ cx stacks listen -s mystack --on 'deploy-finished=notify-send "deployed: $CX_DEPLOY_STATUS"'
 `},
 		cli.Command{
 			Name:  "configure",
@@ -470,6 +497,11 @@ func listStacks(showClusters bool, names []string, environment, output string) {
 }
 
 func printStackList(w io.Writer, stacks []cloud66.Stack, output string) {
+	sort.Sort(stacksByAccountThenName(stacks))
+	if printStructured(stacks) {
+		return
+	}
+
 	if output == "wide" {
 		listRec(w,
 			"ACCOUNT",
@@ -488,7 +520,6 @@ func printStackList(w io.Writer, stacks []cloud66.Stack, output string) {
 			"STATUS",
 			"LAST ACTIVITY")
 	}
-	sort.Sort(stacksByAccountThenName(stacks))
 	for _, stack := range stacks {
 		if stack.Name != "" {
 			listStack(w, stack, output)
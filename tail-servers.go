@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66-oss/cx/term"
+	"github.com/mgutz/ansi"
+)
+
+// tailColors are given to the servers in turn so their lines can be told apart
+var tailColors = []string{"cyan", "green", "yellow", "magenta", "blue", "red"}
+
+// tailOptions are the filters applied to the tailed lines
+type tailOptions struct {
+	Lines   int
+	Grep    *regexp.Regexp
+	Exclude *regexp.Regexp
+	Since   time.Time
+}
+
+// logTimestamps are the timestamp formats found at the start of common log lines (Rails,
+// nginx error and access logs and syslog)
+var logTimestamps = []struct {
+	pattern *regexp.Regexp
+	layouts []string
+}{
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`), []string{
+		"2006-01-02T15:04:05.999999999Z07:00", "2006-01-02T15:04:05.999999999Z0700", "2006-01-02T15:04:05.999999999",
+		"2006-01-02 15:04:05.999999999Z07:00", "2006-01-02 15:04:05.999999999Z0700", "2006-01-02 15:04:05.999999999",
+	}},
+	{regexp.MustCompile(`\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}`), []string{"2006/01/02 15:04:05"}},
+	{regexp.MustCompile(`\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`), []string{"02/Jan/2006:15:04:05 -0700"}},
+	{regexp.MustCompile(`^[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}`), []string{"Jan _2 15:04:05"}},
+}
+
+// logTimestamp finds the timestamp of a log line. Timestamps without a time zone are taken
+// as UTC and the ones without a year as this year
+func logTimestamp(line string) (time.Time, bool) {
+	if len(line) > 80 {
+		line = line[:80]
+	}
+	for _, format := range logTimestamps {
+		match := format.pattern.FindString(line)
+		if match == "" {
+			continue
+		}
+		for _, layout := range format.layouts {
+			if t, err := time.ParseInLocation(layout, match, time.UTC); err == nil {
+				if t.Year() == 0 {
+					t = t.AddDate(time.Now().UTC().Year(), 0, 0)
+				}
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseSince takes a duration (ie. 15m) or a time (ie. 2006-01-02 15:04)
+func parseSince(value string) (time.Time, error) {
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	if t, ok := logTimestamp(value); ok {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %s. Use a duration (ie. 15m) or a time (ie. 2006-01-02 15:04)", value)
+}
+
+// lineFilter returns the filter for the lines of a single file. Lines without a timestamp
+// (like stack traces) go with the line before them
+func (o tailOptions) lineFilter() func(line []byte) bool {
+	started := o.Since.IsZero()
+	return func(line []byte) bool {
+		text := strings.TrimRight(string(line), "\r\n")
+		if !started {
+			if t, ok := logTimestamp(text); !ok || t.Before(o.Since) {
+				return false
+			}
+			started = true
+		}
+		if o.Grep != nil && !o.Grep.MatchString(text) {
+			return false
+		}
+		if o.Exclude != nil && o.Exclude.MatchString(text) {
+			return false
+		}
+		return true
+	}
+}
+
+// tailCommand is the command to follow the log file on the server. Relative names are
+// in the log folder of the stack
+func tailCommand(stack cloud66.Stack, logName string, lines int) string {
+	logPath := logName
+	if !path.IsAbs(logPath) {
+		logPath = path.Join(stack.DeployDir, "web_head", "current", "log", logName)
+	}
+	return fmt.Sprintf("tail -n %d -F %s", lines, shellQuote(logPath))
+}
+
+// tailServers follows all the log files on all the servers, with the server and file in
+// front of each line. It returns when all the tails have stopped, with the number of them
+// that failed
+func tailServers(stack cloud66.Stack, servers []cloud66.Server, logNames []string, options tailOptions) int {
+	// the servers of a stack share the same key so fetch it once
+	sshFile, err := prepareLocalSshKey(servers[0])
+	must(err)
+	must(openServerGateways(servers))
+
+	prefixed := len(servers) > 1 || len(logNames) > 1
+	colored := prefixed && term.IsANSI(os.Stdout)
+	width := 0
+	for _, server := range servers {
+		for _, logName := range logNames {
+			if len(server.Name)+len(logName)+1 > width {
+				width = len(server.Name) + len(logName) + 1
+			}
+		}
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	failed := 0
+	for idx, server := range servers {
+		wg.Add(1)
+		go func(idx int, server cloud66.Server) {
+			defer wg.Done()
+
+			prefix := func(logName string) string {
+				if !prefixed {
+					return ""
+				}
+				text := fmt.Sprintf("%-*s", width, server.Name+" "+logName)
+				if colored {
+					text = ansi.Color(text, tailColors[idx%len(tailColors)])
+				}
+				return text + " | "
+			}
+
+			errs := tailServer(stack, server, sshFile, logNames, options, prefix, &lock)
+			// the ones stopped with Ctrl-C haven't failed
+			if isInterrupted() {
+				return
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			for _, err := range errs {
+				printError("%s: %s", server.Name, err)
+				failed++
+			}
+		}(idx, server)
+	}
+	wg.Wait()
+	closeServerLeases()
+	return failed
+}
+
+func tailServer(stack cloud66.Stack, server cloud66.Server, sshFile string, logNames []string, options tailOptions, prefix func(string) string, lock *sync.Mutex) []error {
+	// open the firewall. The leases are closed together once all the tails are done
+	if _, err := openServerLease(server, 2); err != nil {
+		return []error{err}
+	}
+
+	config, err := serverSshConfig(server, sshFile)
+	if err != nil {
+		return []error{err}
+	}
+	conn, err := dialServer(config)
+	if err != nil {
+		return []error{err}
+	}
+	defer conn.Close()
+
+	var errs []error
+	var errsLock sync.Mutex
+	var wg sync.WaitGroup
+	for _, logName := range logNames {
+		wg.Add(1)
+		go func(logName string) {
+			defer wg.Done()
+
+			stdout := newPrefixWriter(os.Stdout, prefix(logName), lock)
+			stdout.filter = options.lineFilter()
+			stderr := newPrefixWriter(os.Stderr, prefix(logName), lock)
+
+			err := conn.Run(tailCommand(stack, logName, options.Lines), nil, stdout, stderr)
+			stdout.Flush()
+			stderr.Flush()
+			if err != nil {
+				errsLock.Lock()
+				errs = append(errs, fmt.Errorf("%s: %s", logName, err))
+				errsLock.Unlock()
+			}
+		}(logName)
+	}
+	wg.Wait()
+	return errs
+}
@@ -3,7 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
-	"runtime"
+	"regexp"
 
 	"github.com/cloud66-oss/cloud66"
 
@@ -11,49 +11,115 @@ import (
 )
 
 var cmdTail = &Command{
-	Name:       "tail",
-	Build:      buildBasicCommand,
-	Run:        runTail,
+	Name:  "tail",
+	Build: buildBasicCommand,
+	Run:   runTail,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "role",
+			Usage: "tail the logs on all the servers with this role",
+		},
+		cli.StringFlag{
+			Name:  "servers",
+			Usage: "comma separated list of server names or IPs to tail the logs on",
+		},
+		cli.StringFlag{
+			Name:  "grep",
+			Usage: "only show the lines that match this regular expression",
+		},
+		cli.StringFlag{
+			Name:  "exclude",
+			Usage: "hide the lines that match this regular expression",
+		},
+		cli.IntFlag{
+			Name:  "lines,n",
+			Usage: "number of lines to show from the end of each log before following it",
+			Value: 10,
+		},
+		cli.StringFlag{
+			Name:  "since",
+			Usage: "only show the lines logged after this time (ie. 15m or '2006-01-02 15:04')",
+		},
+	},
 	NeedsStack: true,
 	NeedsOrg:   false,
 	Short:      "shows and tails the logfile specified on the given server",
-	Long: `This will run a Linux tail command on the specified server and given logfile.
+	Long: `This will run a Linux tail command on the specified server and given logfiles.
 Logs are read from stack's log folder (current/log) and should be the full logfile name
-including the extension.
+including the extension. Logfiles with an absolute path are read from that path.
 
 Server names and roles are case insensitive and will work with the starting characters as well.
 
-This command is only supported on Linux and OS X.
+To tail the logs on several servers at once, use --role and/or --servers instead of the server name.
+All the given logfiles are tailed on all the servers and their lines are merged as they come,
+with the server and logfile in front of each line, in a different color for each server.
+
+Use --grep and --exclude to only show or hide the lines that match a regular expression, and
+-n to change the number of lines shown from the end of each logfile (10 by default).
+For logs with timestamps (Rails, nginx and syslog), --since only shows the lines logged after
+the given time or duration. Timestamps without a time zone are taken as UTC. As the lines are
+read from the end of the logfile, use -n to read far back enough (1000 by default with --since).
+
+If the server is behind a deploy gateway (bastion server), provide the key to the gateway with --gateway-key.
+A closed gateway is opened for 20 minutes before connecting.
 
 Examples:
 $ cx tail -s mystack production.log
 $ cx tail -s mystack 52.65.34.98 nginx_error.log
 $ cx tail -s mystack web staging.log
+$ cx tail -s mystack --role web production.log nginx_error.log
+$ cx tail -s mystack --servers lion,tiger --grep 'Completed 5\d\d' production.log
+$ cx tail -s mystack --role web --since 15m --exclude health_check production.log
 `,
 }
 
 func runTail(c *cli.Context) {
-	if runtime.GOOS == "windows" {
-		printFatal("Not supported on Windows")
-		os.Exit(2)
-	}
-
 	stack := mustStack(c)
 
-	if len(c.Args()) != 2 {
-		//cmd.printUsage()
-		os.Exit(2)
+	options := tailOptions{Lines: c.Int("lines")}
+	var err error
+	if c.String("grep") != "" {
+		if options.Grep, err = regexp.Compile(c.String("grep")); err != nil {
+			printFatal("invalid --grep: %s", err)
+		}
+	}
+	if c.String("exclude") != "" {
+		if options.Exclude, err = regexp.Compile(c.String("exclude")); err != nil {
+			printFatal("invalid --exclude: %s", err)
+		}
+	}
+	if c.String("since") != "" {
+		if options.Since, err = parseSince(c.String("since")); err != nil {
+			printFatal(err.Error())
+		}
+		if !c.IsSet("lines") {
+			options.Lines = 1000
+		}
 	}
-
-	// get the server
-	serverName := c.Args()[0]
-	logName := c.Args()[1]
 
 	servers, err := client.Servers(stack.Uid)
 	if err != nil {
 		printFatal(err.Error())
 	}
 
+	args := []string(c.Args())
+	if c.String("role") != "" || c.String("servers") != "" {
+		if len(args) == 0 {
+			printFatal("No logfile specified")
+		}
+		if tailServers(*stack, findServersForRun(servers, c.String("role"), c.String("servers")), args, options) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) < 2 {
+		cli.ShowCommandHelp(c, "tail")
+		os.Exit(2)
+	}
+
+	// get the server
+	serverName := args[0]
 	server, err := findServer(servers, serverName)
 	if err != nil {
 		printFatal(err.Error())
@@ -65,36 +131,7 @@ func runTail(c *cli.Context) {
 
 	fmt.Printf("Server: %s\n", server.Name)
 
-	err = tailLog(*stack, *server, logName)
-	if err != nil {
-		printFatal(err.Error())
+	if tailServers(*stack, []cloud66.Server{*server}, args[1:], options) > 0 {
+		os.Exit(1)
 	}
 }
-
-func tailLog(stack cloud66.Stack, server cloud66.Server, logName string) error {
-	sshFile, err := prepareLocalSshKey(server)
-	must(err)
-
-	// open the firewall
-	var timeToOpen = 2
-	fmt.Printf("Opening access to %s...\n", server.Address)
-	genericRes, err := client.LeaseSync(server.StackUid, nil, &timeToOpen, nil, &server.Uid)
-	must(err)
-	if genericRes.Status != true {
-		printFatal("Unable to open server lease")
-	}
-
-	fmt.Printf("Connecting to %s (%s)...\n", server.Name, server.Address)
-	return startProgram("ssh", []string{
-		server.UserName + "@" + server.Address,
-		"-i", sshFile,
-		"-o", "UserKnownHostsFile=/dev/null",
-		"-o", "CheckHostIP=no",
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "LogLevel=QUIET",
-		"-o", "IdentitiesOnly=yes",
-		"-A",
-		"-p", "22",
-		fmt.Sprintf("tail -f '%s/web_head/current/log/%s'", stack.DeployDir, logName),
-	})
-}
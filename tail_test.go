@@ -0,0 +1,54 @@
+package main
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tail", func() {
+	Context("reading log timestamps", func() {
+		It("should find the timestamps of common logs", func() {
+			expected := time.Date(2024, 3, 26, 11, 23, 45, 0, time.UTC)
+			for _, line := range []string{
+				"I, [2024-03-26T11:23:45.123456 #123]  INFO -- : Started GET \"/\"",
+				"2024-03-26T11:23:45Z level=info msg=started",
+				"2024/03/26 11:23:45 [error] 123#0: *1 connect() failed",
+				"1.2.3.4 - - [26/Mar/2024:12:23:45 +0100] \"GET / HTTP/1.1\" 200 612",
+			} {
+				t, ok := logTimestamp(line)
+				Expect(ok).To(BeTrue(), line)
+				Expect(t.Truncate(time.Second).Equal(expected)).To(BeTrue(), line)
+			}
+
+			_, ok := logTimestamp("  from app/models/user.rb:12:in `save'")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Context("filtering lines", func() {
+		It("should keep the lines after --since with the lines that follow them", func() {
+			filter := tailOptions{Since: time.Date(2024, 3, 26, 11, 0, 0, 0, time.UTC)}.lineFilter()
+			Expect(filter([]byte("2024-03-26 10:59:59 old\n"))).To(BeFalse())
+			Expect(filter([]byte("  stack trace of old\n"))).To(BeFalse())
+			Expect(filter([]byte("2024-03-26 11:00:01 new\n"))).To(BeTrue())
+			Expect(filter([]byte("  stack trace of new\n"))).To(BeTrue())
+		})
+
+		It("should apply --grep and --exclude", func() {
+			filter := tailOptions{Grep: regexp.MustCompile(`GET`), Exclude: regexp.MustCompile(`health`)}.lineFilter()
+			Expect(filter([]byte("GET /users\n"))).To(BeTrue())
+			Expect(filter([]byte("POST /users\n"))).To(BeFalse())
+			Expect(filter([]byte("GET /health\n"))).To(BeFalse())
+		})
+	})
+
+	It("should quote the log name in the tail command", func() {
+		stack := cloud66.Stack{DeployDir: "/var/deploy/shop"}
+		Expect(tailCommand(stack, "production.log", 10)).To(Equal("tail -n 10 -F '/var/deploy/shop/web_head/current/log/production.log'"))
+		Expect(tailCommand(stack, "/var/log/it's.log", 10)).To(Equal(`tail -n 10 -F '/var/log/it'\''s.log'`))
+	})
+})
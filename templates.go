@@ -100,6 +100,9 @@ func runResyncTemplate(c *cli.Context) {
 }
 
 func printBaseTemplates(w io.Writer, baseTemplates []cloud66.BaseTemplate) {
+	if printStructured(baseTemplates) {
+		return
+	}
 	printBaseTemplateHeader(w)
 	for _, baseTemplate := range baseTemplates {
 		printBaseTemplateRow(w, &baseTemplate)
@@ -107,6 +110,9 @@ func printBaseTemplates(w io.Writer, baseTemplates []cloud66.BaseTemplate) {
 }
 
 func printBaseTemplate(w io.Writer, baseTemplate *cloud66.BaseTemplate) {
+	if printStructured(baseTemplate) {
+		return
+	}
 	printBaseTemplateHeader(w)
 	printBaseTemplateRow(w, baseTemplate)
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// tunnelPresetsFile holds the tunnel presets of a project, next to its code
+const tunnelPresetsFile = ".cx-tunnels.yml"
+
+// TunnelPreset is a named set of port forwards opened together with cx tunnel <name>
+type TunnelPreset struct {
+	Stack       string   `json:"stack,omitempty" yaml:"stack,omitempty"`
+	Environment string   `json:"environment,omitempty" yaml:"environment,omitempty"`
+	Forwards    []string `json:"forwards" yaml:"forwards"`
+}
+
+// socksForward is used in place of the remote port for a SOCKS proxy (local:server:socks)
+const socksForward = "socks"
+
+// tunnelForward is a local port forwarded to a port on a server (local:server:remote) or
+// a SOCKS proxy through the server
+type tunnelForward struct {
+	LocalPort  int
+	Server     string
+	RemotePort int
+	Socks      bool
+}
+
+func (f tunnelForward) String() string {
+	if f.Socks {
+		return fmt.Sprintf("%d:%s:%s", f.LocalPort, f.Server, socksForward)
+	}
+	return fmt.Sprintf("%d:%s:%d", f.LocalPort, f.Server, f.RemotePort)
+}
+
+// parseTunnelForward parses a local:server:remote forward. The server can be a name, IP or role
+func parseTunnelForward(spec string) (tunnelForward, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) != 3 || parts[1] == "" {
+		return tunnelForward{}, fmt.Errorf("invalid forward '%s'. Use local:server:remote (ie. 5433:db:5432)", spec)
+	}
+	local, err := strconv.Atoi(parts[0])
+	if err != nil || local <= 0 || local > 65535 {
+		return tunnelForward{}, fmt.Errorf("invalid local port in '%s'", spec)
+	}
+	if strings.EqualFold(parts[2], socksForward) {
+		return tunnelForward{LocalPort: local, Server: parts[1], Socks: true}, nil
+	}
+	remote, err := strconv.Atoi(parts[2])
+	if err != nil || remote <= 0 || remote > 65535 {
+		return tunnelForward{}, fmt.Errorf("invalid remote port in '%s'", spec)
+	}
+	return tunnelForward{LocalPort: local, Server: parts[1], RemotePort: remote}, nil
+}
+
+// readTunnelPresets reads the presets of a project file
+func readTunnelPresets(path string) (map[string]*TunnelPreset, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var presets map[string]*TunnelPreset
+	if err := yaml.Unmarshal(buf, &presets); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", path, err)
+	}
+	return presets, nil
+}
+
+// findTunnelPreset looks for the preset in the project file of the current directory first
+// and then in the profile
+func findTunnelPreset(name string) (*TunnelPreset, error) {
+	presets, err := readTunnelPresets(tunnelPresetsFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if preset, ok := presets[name]; ok && preset != nil {
+		return preset, nil
+	}
+	if preset, ok := selectedProfile.Tunnels[name]; ok && preset != nil {
+		return preset, nil
+	}
+
+	var names []string
+	for presetName := range presets {
+		names = append(names, presetName)
+	}
+	for presetName := range selectedProfile.Tunnels {
+		names = append(names, presetName)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no tunnel preset named %s found. Presets are kept in %s or in the profile (see --save)", name, tunnelPresetsFile)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("no tunnel preset named %s found. Available presets: %s", name, strings.Join(names, ", "))
+}
+
+// saveTunnelPreset stores the preset in the current profile
+func saveTunnelPreset(name string, preset *TunnelPreset) error {
+	profiles := readProfiles()
+	profile := findProfile(profiles, selectedProfile.Name)
+	if profile.Tunnels == nil {
+		profile.Tunnels = map[string]*TunnelPreset{}
+	}
+	profile.Tunnels[name] = preset
+	selectedProfile.Tunnels = profile.Tunnels
+
+	return profiles.WriteProfiles()
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloud66-oss/cloud66"
+	"github.com/cloud66-oss/cx/sshclient"
+	"github.com/cloud66-oss/cx/term"
+)
+
+// tunnelMaxBackoff is the longest wait between two attempts to reconnect a tunnel
+const tunnelMaxBackoff = 30 * time.Second
+
+// tunnelStatus is the state of a single forward in a tunnel session
+type tunnelStatus struct {
+	forward  tunnelForward
+	server   cloud66.Server
+	listener net.Listener
+	active   int32
+
+	state      string
+	err        error
+	since      time.Time
+	reconnects int
+}
+
+// tunnelSession runs the forwards to all the servers and shows a status line for each one
+type tunnelSession struct {
+	lock    sync.Mutex
+	forward []*tunnelStatus
+	live    bool
+	drawn   int
+}
+
+func newTunnelSession() *tunnelSession {
+	return &tunnelSession{live: term.IsANSI(os.Stdout)}
+}
+
+// add listens on the local port of the forward
+func (t *tunnelSession) add(forward tunnelForward, server cloud66.Server) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", forward.LocalPort))
+	if err != nil {
+		return err
+	}
+	status := &tunnelStatus{forward: forward, server: server, state: "waiting", since: time.Now()}
+	status.listener = &countingListener{TCPListener: listener.(*net.TCPListener), active: &status.active}
+	t.forward = append(t.forward, status)
+	return nil
+}
+
+// run connects to each server and forwards the ports until the session is stopped
+func (t *tunnelSession) run() {
+	servers := map[string][]*tunnelStatus{}
+	var order []string
+	for _, status := range t.forward {
+		if _, ok := servers[status.server.Uid]; !ok {
+			order = append(order, status.server.Uid)
+		}
+		servers[status.server.Uid] = append(servers[status.server.Uid], status)
+	}
+
+	var wg sync.WaitGroup
+	for _, uid := range order {
+		wg.Add(1)
+		go func(forwards []*tunnelStatus) {
+			defer wg.Done()
+			t.runServer(forwards[0].server, forwards)
+		}(servers[uid])
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			if t.live {
+				t.draw()
+			}
+			return
+		case <-ticker.C:
+			if t.live {
+				t.draw()
+			}
+		}
+	}
+}
+
+// runServer keeps the forwards of a server open, reconnecting with a backoff when the
+// connection drops
+func (t *tunnelSession) runServer(server cloud66.Server, forwards []*tunnelStatus) {
+	sshFile, err := prepareLocalSshKey(server)
+	if err != nil {
+		t.update(forwards, "failed", err)
+		return
+	}
+	if _, err := openServerLease(server, 2); err != nil {
+		t.update(forwards, "failed", err)
+		return
+	}
+	config, err := serverSshConfig(server, sshFile)
+	if err != nil {
+		t.update(forwards, "failed", err)
+		return
+	}
+
+	backoff := time.Second
+	for {
+		t.update(forwards, "connecting", nil)
+		conn, err := dialServer(config)
+		if err == nil {
+			backoff = time.Second
+			t.update(forwards, "open", nil)
+			err = forwardAll(conn, forwards)
+			conn.Close()
+			if isInterrupted() {
+				t.update(forwards, "closed", nil)
+				return
+			}
+			if err != sshclient.ErrConnectionLost {
+				t.update(forwards, "failed", err)
+				return
+			}
+		} else if sshclient.IsHostKeyChanged(err) {
+			t.update(forwards, "failed", err)
+			return
+		}
+
+		t.update(forwards, fmt.Sprintf("reconnecting in %s", backoff), err)
+		select {
+		case <-interrupted():
+			t.update(forwards, "closed", nil)
+			return
+		case <-time.After(backoff):
+		}
+		for _, status := range forwards {
+			t.lock.Lock()
+			status.reconnects++
+			t.lock.Unlock()
+		}
+		backoff *= 2
+		if backoff > tunnelMaxBackoff {
+			backoff = tunnelMaxBackoff
+		}
+	}
+}
+
+// forwardAll forwards all the ports over the connection. It returns ErrConnectionLost when the
+// connection drops or the first other error, after stopping the rest of the forwards
+func forwardAll(conn *sshclient.Client, forwards []*tunnelStatus) error {
+	errs := make(chan error, len(forwards))
+	for _, status := range forwards {
+		go func(status *tunnelStatus) {
+			if status.forward.Socks {
+				errs <- conn.ServeSOCKS(status.listener)
+				return
+			}
+			remoteAddress := fmt.Sprintf("%s:%d", status.server.Address, status.forward.RemotePort)
+			errs <- conn.Forward(status.listener, remoteAddress)
+		}(status)
+	}
+
+	var result error
+	for range forwards {
+		err := <-errs
+		if result == nil || result == sshclient.ErrConnectionLost {
+			result = err
+		}
+		if err != sshclient.ErrConnectionLost {
+			// stops the other forwards
+			conn.Close()
+		}
+	}
+	return result
+}
+
+func (t *tunnelSession) update(forwards []*tunnelStatus, state string, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, status := range forwards {
+		if status.state != state {
+			status.since = time.Now()
+		}
+		status.state = state
+		status.err = err
+
+		if !t.live {
+			fmt.Println(strings.Replace(t.statusLine(status), "\t", "  ", -1))
+		}
+	}
+}
+
+// draw rewrites the status lines in place
+func (t *tunnelSession) draw() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.drawn > 0 {
+		fmt.Printf("\033[%dA", t.drawn)
+	}
+	var buf strings.Builder
+	w := tabwriter.NewWriter(&buf, 1, 2, 2, ' ', 0)
+	for _, status := range t.forward {
+		fmt.Fprintln(w, t.statusLine(status))
+	}
+	w.Flush()
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if line != "" {
+			fmt.Print("\r\033[2K" + line)
+		}
+	}
+	t.drawn = len(t.forward)
+}
+
+func (t *tunnelSession) statusLine(status *tunnelStatus) string {
+	target := fmt.Sprintf("%s:%d", status.server.Name, status.forward.RemotePort)
+	if status.forward.Socks {
+		target = status.server.Name + " (SOCKS5)"
+	}
+	line := fmt.Sprintf("127.0.0.1:%d -> %s\t%s\t%s",
+		status.forward.LocalPort, target, status.state, time.Since(status.since).Round(time.Second))
+	if status.state == "open" {
+		line += fmt.Sprintf("\t%d connections", atomic.LoadInt32(&status.active))
+	} else if status.err != nil {
+		line += "\t" + status.err.Error()
+	}
+	if status.reconnects > 0 {
+		line += fmt.Sprintf("\t(reconnected %d times)", status.reconnects)
+	}
+	return line
+}
+
+// countingListener keeps count of the open connections accepted on it
+type countingListener struct {
+	*net.TCPListener
+	active *int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.AcceptTCP()
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt32(l.active, 1)
+	return &countingConn{TCPConn: conn, active: l.active}, nil
+}
+
+type countingConn struct {
+	*net.TCPConn
+	active *int32
+	once   sync.Once
+}
+
+func (c *countingConn) Close() error {
+	c.once.Do(func() { atomic.AddInt32(c.active, -1) })
+	return c.TCPConn.Close()
+}
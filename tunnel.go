@@ -3,10 +3,8 @@ package main
 import (
 	"fmt"
 	"os"
-	"runtime"
 
 	"github.com/cloud66-oss/cloud66"
-
 	"github.com/cloud66/cli"
 )
 
@@ -26,6 +24,19 @@ var cmdTunnel = &Command{
 			Name:  "remote,r",
 			Usage: "remote port for the tunnel",
 		},
+		cli.StringSliceFlag{
+			Name:  "forward,L",
+			Value: &cli.StringSlice{},
+			Usage: "forward in local:server:remote format. Can be repeated",
+		},
+		cli.IntFlag{
+			Name:  "socks",
+			Usage: "local port for a SOCKS5 proxy through the server",
+		},
+		cli.StringFlag{
+			Name:  "save",
+			Usage: "save the forwards as a preset with this name in the profile",
+		},
 	},
 	Run:        runTunnel,
 	NeedsStack: true,
@@ -47,13 +58,12 @@ You need to have the correct access permissions to use this command.
 You can use either the server name (ie lion) or the server IP (ie. 123.123.123.123) or the server role (ie. web)
 with thie command.
 
-This command doesn't work with stacks using gateways (Bastion servers).
+If the server is behind a deploy gateway (bastion server), provide the key to the gateway with --gateway-key.
+A closed gateway is opened for 20 minutes before connecting.
 
 If a role is specified the command will connect to the first server with that role.
 Names are case insensitive and will work with the starting characters as well.
 
-This command is only supported on Linux and OS X (for Windows you can run this in a virtual machine if necessary)
-
 To specify the ports for the tunnel, use --local and --remote options. 
 
 For example, if you need to connect to a MySQL server, you can use 3307 locally and 3306 (MySQL port on the server) as the remote port.
@@ -62,73 +72,131 @@ Once the tunnel is established, you can use your favourite MySQL client to conne
 If a local port is not specified, cx will use remote + 1 as a convention for the local port.
 For example, if you only specify --remote 5432 without explicitly specifying local, cx will use 5433 as the local port.
 
+To open several tunnels at once, possibly to different servers, use -L local:server:remote as many times as needed.
+cx shows a status line for each tunnel and reconnects the tunnels of a server if its connection drops.
+
+To reach any address the server can reach, like the private IPs of other servers or containers
+(see 'cx containers list'), use --socks with a local port. This opens a SOCKS5 proxy through the server (like ssh -D)
+that browsers and other tools can use. Host names are resolved on the server. In -L and presets, use
+local:server:socks for a SOCKS5 proxy.
+
+A set of tunnels can be saved as a preset with --save and opened later by its name. Presets are kept in the
+current profile, or in a .cx-tunnels.yml file in the current directory to share them with the project:
+
+db-stack:
+  stack: mystack
+  environment: production
+  forwards:
+    - 5433:db:5432
+    - 6380:redis:6379
+
 Examples:
 $ cx tunnel -s mystack --server lion --local 3307 --remote 3306
-$ cx run -s mystack --server 52.65.34.98 --local 3307 --remote 3306
-$ cx run -s mystack --server web -l 3307 -r 3306
+$ cx tunnel -s mystack --server 52.65.34.98 --local 3307 --remote 3306
+$ cx tunnel -s mystack --server web -l 3307 -r 3306
+$ cx tunnel -s mystack -L 5433:db:5432 -L 6380:redis:6379 -L 8081:web:8080
+$ cx tunnel -s mystack --server web --socks 1080
+$ curl --socks5-hostname 127.0.0.1:1080 http://10.0.1.12:8080
+$ cx tunnel -s mystack -L 5433:db:5432 -L 6380:redis:6379 --save db-stack
+$ cx tunnel db-stack
 `,
 }
 
 func runTunnel(c *cli.Context) {
-	if runtime.GOOS == "windows" {
-		printFatal("Not supported on Windows")
-		os.Exit(2)
+	var specs []string
+	if len(c.Args()) > 0 {
+		preset, err := findTunnelPreset(c.Args().First())
+		if err != nil {
+			printFatal(err.Error())
+		}
+		specs = append(specs, preset.Forwards...)
+
+		if preset.Stack != "" && !c.IsSet("stack") {
+			environment := c.String("environment")
+			if preset.Environment != "" && !c.IsSet("environment") {
+				environment = preset.Environment
+			}
+			flagStack, err = stackByName(preset.Stack, environment)
+			if err != nil {
+				printFatal(err.Error())
+			}
+		}
 	}
 
-	stack := mustStack(c)
-	serverName := c.String("server")
-	servers, err := client.Servers(stack.Uid)
-	if err != nil {
-		printFatal(err.Error())
+	specs = append(specs, c.StringSlice("forward")...)
+	if c.IsSet("socks") {
+		serverName := c.String("server")
+		if serverName == "" {
+			printFatal("No server specified for the SOCKS proxy. Use --server")
+		}
+		specs = append(specs, tunnelForward{LocalPort: c.Int("socks"), Server: serverName, Socks: true}.String())
 	}
-
-	server, err := findServer(servers, serverName)
-	if err != nil {
-		printFatal(err.Error())
+	if c.IsSet("remote") || (c.IsSet("server") && !c.IsSet("socks")) {
+		if !c.IsSet("remote") {
+			printFatal("No remote port specified. Use --remote")
+		}
+		if c.String("server") == "" {
+			printFatal("No server specified. Use --server")
+		}
+		remotePort := c.Int("remote")
+		localPort := remotePort + 1
+		if c.IsSet("local") {
+			localPort = c.Int("local")
+		}
+		specs = append(specs, tunnelForward{LocalPort: localPort, Server: c.String("server"), RemotePort: remotePort}.String())
 	}
-	if server == nil {
-		printFatal("Server '" + serverName + "' not found")
+	if len(specs) == 0 {
+		printFatal("No forward specified. Use -L local:server:remote, --remote, --socks or a preset name")
 	}
-	if !c.IsSet("remote") {
-		printFatal("No remote port specified. Use --remote")
-	}
-	remotePort := c.Int("remote")
-	localPort := remotePort + 1
-	if c.IsSet("local") {
-		localPort = c.Int("local")
+
+	var forwards []tunnelForward
+	for _, spec := range specs {
+		forward, err := parseTunnelForward(spec)
+		if err != nil {
+			printFatal(err.Error())
+		}
+		forwards = append(forwards, forward)
 	}
 
-	err = TunnelToServer(*server, localPort, remotePort)
+	stack := mustStack(c)
+	servers, err := client.Servers(stack.Uid)
 	if err != nil {
 		printFatal(err.Error())
 	}
-}
 
-func TunnelToServer(server cloud66.Server, localPort int, remotePort int) error {
-	sshFile, err := prepareLocalSshKey(server)
-	must(err)
+	if name := c.String("save"); name != "" {
+		saved := &TunnelPreset{Stack: stack.Name, Environment: stack.Environment, Forwards: specs}
+		if err := saveTunnelPreset(name, saved); err != nil {
+			printFatal(err.Error())
+		}
+		fmt.Printf("Saved tunnel preset %s. Use 'cx tunnel %s' to open it\n", name, name)
+	}
 
-	// open the firewall
-	var timeToOpen = 2
-	genericRes, err := client.LeaseSync(server.StackUid, nil, &timeToOpen, nil, &server.Uid)
-	must(err)
-	if genericRes.Status != true {
-		printFatal("Unable to open server lease")
+	session := newTunnelSession()
+	var sessionServers []cloud66.Server
+	for _, forward := range forwards {
+		server, err := findServer(servers, forward.Server)
+		if err != nil {
+			printFatal(err.Error())
+		}
+		if server == nil {
+			printFatal("Server '" + forward.Server + "' not found")
+		}
+		if err := session.add(forward, *server); err != nil {
+			printFatal(err.Error())
+		}
+		sessionServers = append(sessionServers, *server)
+	}
+	if err := openServerGateways(sessionServers); err != nil {
+		printFatal(err.Error())
 	}
 
-	fmt.Printf("Opening Tunnel from local:%d to %s:%d (127.0.0.1:%d to %s:%d)...\n", localPort, server.Name, remotePort, localPort, server.Address, remotePort)
 	fmt.Println("Press Ctrl-C to exit")
+	session.run()
+	closeServerLeases()
 
-	return startProgram("ssh", []string{
-		server.UserName + "@" + server.Address,
-		"-i", sshFile,
-		"-o", "UserKnownHostsFile=/dev/null",
-		"-o", "CheckHostIP=no",
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "LogLevel=QUIET",
-		"-o", "IdentitiesOnly=yes",
-		"-N",
-		"-L", fmt.Sprintf("%d:%s:%d", localPort, server.Address, remotePort),
-		"-p", "22",
-	})
+	// otherwise the session only ends when all the forwards have failed
+	if !isInterrupted() {
+		os.Exit(1)
+	}
 }
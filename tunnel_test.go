@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tunnel", func() {
+	Context("parsing forwards", func() {
+		It("should parse local:server:remote", func() {
+			forward, err := parseTunnelForward("5433:db:5432")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(forward).To(Equal(tunnelForward{LocalPort: 5433, Server: "db", RemotePort: 5432}))
+			Expect(forward.String()).To(Equal("5433:db:5432"))
+		})
+
+		It("should parse a SOCKS proxy", func() {
+			forward, err := parseTunnelForward("1080:web:socks")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(forward).To(Equal(tunnelForward{LocalPort: 1080, Server: "web", Socks: true}))
+			Expect(forward.String()).To(Equal("1080:web:socks"))
+		})
+
+		It("should refuse invalid forwards", func() {
+			for _, spec := range []string{"5432", "5433:db", "5433::5432", "x:db:5432", "5433:db:70000", "1:2:3:4"} {
+				_, err := parseTunnelForward(spec)
+				Expect(err).To(HaveOccurred(), spec)
+			}
+		})
+	})
+
+	Context("reading presets", func() {
+		It("should read the presets of a project", func() {
+			dir, err := ioutil.TempDir("", "cx-tunnels")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, tunnelPresetsFile)
+			Expect(ioutil.WriteFile(path, []byte(`db-stack:
+  stack: mystack
+  environment: production
+  forwards:
+    - 5433:db:5432
+    - 6380:redis:6379
+`), 0644)).To(Succeed())
+
+			presets, err := readTunnelPresets(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(presets).To(HaveKey("db-stack"))
+			Expect(presets["db-stack"].Stack).To(Equal("mystack"))
+			Expect(presets["db-stack"].Environment).To(Equal("production"))
+			Expect(presets["db-stack"].Forwards).To(Equal([]string{"5433:db:5432", "6380:redis:6379"}))
+		})
+	})
+})
@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"runtime"
 
 	"github.com/cloud66-oss/cloud66"
 
@@ -40,7 +39,8 @@ If a role is specified the command will connect to the first server with that ro
 
 Names are case insensitive and will work with the starting characters as well.
 
-This command is only supported on Linux and OS X.
+If the server is behind a deploy gateway (bastion server), provide the key to the gateway with --gateway-key.
+A closed gateway is opened for 20 minutes before connecting.
 
 Examples:
 $ cx upload -s mystack --server lion /path/to/source/file
@@ -51,11 +51,6 @@ $ cx upload -s mystack --server web /path/to/source/file /path/to/target/directo
 }
 
 func runUpload(c *cli.Context) {
-	if runtime.GOOS == "windows" {
-		printFatal("Not supported on Windows")
-		os.Exit(2)
-	}
-
 	stack := mustStack(c)
 
 	// args start after stack name
@@ -116,26 +111,25 @@ func sshToServerToUpload(server cloud66.Server, filePath string, targetDirectory
 	must(err)
 
 	// open the firewall
-	var timeToOpen = 2
 	fmt.Printf("Opening access to %s...\n", server.Address)
-	genericRes, err := client.LeaseSync(server.StackUid, nil, &timeToOpen, nil, &server.Uid)
-	must(err)
-	if genericRes.Status != true {
-		printFatal("Unable to open server lease")
+	lease := mustOpenServerLease(server, 2)
+	defer lease.Close()
+
+	if err := openServerGateways([]cloud66.Server{server}); err != nil {
+		return err
+	}
+	config, err := serverSshConfig(server, sshFile)
+	if err != nil {
+		return err
 	}
 
 	fmt.Printf("Connecting to %s (%s)...\n", server.Name, server.Address)
 
-	return startProgram("scp", []string{
-		"-i", sshFile,
-		"-r",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"-o", "CheckHostIP=no",
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "LogLevel=QUIET",
-		"-o", "IdentitiesOnly=yes",
-		"-P", "22",
-		filePath,
-		server.UserName + "@" + server.Address + ":" + targetDir,
-	})
+	conn, err := dialServer(config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Upload(filePath, targetDir)
 }
@@ -185,6 +185,10 @@ func runApplyProfile(c *cli.Context) {
 }
 
 func printUsersList(w io.Writer, users []cloud66.User) {
+	if printStructured(users) {
+		return
+	}
+
 	listRec(w,
 		"Id",
 		"Email",
@@ -199,6 +203,10 @@ func printUsersList(w io.Writer, users []cloud66.User) {
 }
 
 func printUser(u *cloud66.User) {
+	if printStructured(u) {
+		return
+	}
+
 	fmt.Printf("Email: %s\n", u.Email)
 	fmt.Printf("Locked: %t\n", u.Locked)
 	fmt.Println("Access Profile")
@@ -9,7 +9,6 @@ import (
 	"io/ioutil"
 	"log"
 	"net"
-	"net/http"
 	"os"
 	"os/exec"
 	"os/user"
@@ -24,8 +23,6 @@ import (
 	"github.com/mgutz/ansi"
 )
 
-var lastCommandExecuted *exec.Cmd
-
 func cxHome() string {
 	return filepath.Join(homePath(), ".cloud66")
 }
@@ -43,7 +40,7 @@ func fileExists(path string) (bool, error) {
 }
 
 func expandPath(filePath string) string {
-	if filePath[:2] == "~/" {
+	if strings.HasPrefix(filePath, "~/") {
 		usr, _ := user.Current()
 		dir := usr.HomeDir
 		return strings.Replace(filePath, "~", dir, 1)
@@ -271,27 +268,6 @@ func openURL(url string) error {
 	return runCommand(command, args, os.Environ())
 }
 
-func downloadFile(source string, output string) error {
-	out, err := os.Create(output)
-	defer out.Close()
-	if err != nil {
-		return err
-	}
-
-	resp, err := http.Get(source)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	_, err = io.Copy(out, resp.Body)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
 // runs the command using OS specific commands
 func runCommand(command string, args, env []string) error {
 	if runtime.GOOS != "windows" {
@@ -309,38 +285,6 @@ func runCommand(command string, args, env []string) error {
 	return sysExec(command, args, env)
 }
 
-func startProgram(command string, args []string) error {
-
-	if runtime.GOOS != "windows" {
-		p, err := exec.LookPath(command)
-		if err != nil {
-			log.Printf("Error finding path to %q: %s\n", command, err)
-			os.Exit(2)
-		}
-		command = p
-	}
-
-	if debugMode {
-		fmt.Printf("Running Command %s with (%s)\n", command, args)
-	}
-
-	cmd := exec.Command(command, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-
-	//TODO: ugly to mock startProgram when testing, need better solution
-	if underTest {
-		lastCommandExecuted = cmd
-		return nil
-	}
-
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-	return nil
-}
-
 func clearSshKeyCache() error {
 	dir := filepath.Join(homePath(), ".ssh")
 	files, err := ioutil.ReadDir(dir)
@@ -381,7 +325,7 @@ func prepareLocalSshKey(server cloud66.Server) (string, error) {
 	// do we have the key?
 	if b, _ := fileExists(sshFile); !b {
 		// get the content and write the file
-		fmt.Println("Fetching SSH key...")
+		fmt.Fprintln(os.Stderr, "Fetching SSH key...")
 		sshKey, err := client.ServerKeyInformation(server.StackUid, server.Uid)
 		if err != nil {
 			return "", err
@@ -391,7 +335,7 @@ func prepareLocalSshKey(server cloud66.Server) (string, error) {
 		}
 	} else {
 		if debugMode {
-			fmt.Println("Found an existing SSH key for this server")
+			fmt.Fprintln(os.Stderr, "Found an existing SSH key for this server")
 		}
 	}
 	return sshFile, nil